@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminSearchUsers lists users for the admin console
+// Backend: GET /api/admin/users (admin role)
+// Query params: q (username/email substring), role, suspended (true/false),
+// page (default 1), limit (default 20, max 100)
+// Response: { users: [user], page, limit, total }
+func AdminSearchUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	search := model.UserSearch{
+		Query: query.Get("q"),
+		Role:  query.Get("role"),
+	}
+	if value := query.Get("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "suspended must be true or false")
+			return
+		}
+		search.Suspended = &suspended
+	}
+
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), 20)
+	if limit > 100 {
+		limit = 100 // Cap at 100 to prevent large transfers
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	users, total, err := service.UserServiceInstance.SearchUsers(ctx, search, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching users")
+		return
+	}
+
+	if users == nil {
+		users = []model.User{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": users,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// AdminGetUser returns one user's account details
+// Backend: GET /api/admin/users/{id} (admin role)
+func AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var user model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, mux.Vars(r)["id"], &user); err != nil {
+		writeServiceError(w, r, err, "Error fetching user")
+		return
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+// AdminUpdateUserRole grants or removes the admin role
+// Backend: PUT /api/admin/users/{id}/role (admin role)
+// Request body: { role: "user" | "admin" }
+// Errors: 409 cannot_modify_self when admins change their own role
+func AdminUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var before model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, mux.Vars(r)["id"], &before); err != nil {
+		writeServiceError(w, r, err, "Error updating role")
+		return
+	}
+
+	user, err := service.UserServiceInstance.SetRole(ctx, claims.UserID, before.ID.Hex(), req.Role)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating role")
+		return
+	}
+
+	recordAudit(r, model.AuditActionUserRoleChange, "user", user.ID.Hex(), before, user)
+
+	json.NewEncoder(w).Encode(user)
+}
+
+// AdminAdjustPoints credits or debits a user's points by hand
+// Backend: POST /api/admin/users/{id}/points (admin role)
+// Request body: { amount: 50 | -50, reason: "Compensation for outage" }
+// Response: 201 { transaction, balance }
+// Errors: 400 when amount is 0 or reason is empty, 402 when a debit would overdraw
+func AdminAdjustPoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	userID := mux.Vars(r)["id"]
+
+	var req struct {
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	transaction, err := service.LedgerServiceInstance.Adjust(ctx, userID, req.Amount, req.Reason, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error adjusting points")
+		return
+	}
+
+	var user model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, userID, &user); err != nil {
+		writeServiceError(w, r, err, "Error fetching user")
+		return
+	}
+
+	recordAudit(r, model.AuditActionPointsAdjust, "user", userID,
+		map[string]interface{}{"points": user.Points - transaction.Amount},
+		map[string]interface{}{"points": user.Points, "transaction": transaction},
+	)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transaction": transaction,
+		"balance":     user.Points,
+	})
+}
+
+// AdminSetUserSuspension suspends or reinstates an account
+// Backend: PUT /api/admin/users/{id}/suspension (admin role)
+// Request body: { suspended: true, reason: "Abuse of referral program" }
+// Suspended users are signed out everywhere and refused at login and token refresh
+// Errors: 409 cannot_modify_self when admins suspend themselves
+func AdminSetUserSuspension(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		Suspended bool   `json:"suspended"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var before model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, mux.Vars(r)["id"], &before); err != nil {
+		writeServiceError(w, r, err, "Error updating suspension")
+		return
+	}
+
+	user, err := service.UserServiceInstance.SetSuspended(ctx, claims.UserID, before.ID.Hex(), req.Suspended, req.Reason)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating suspension")
+		return
+	}
+
+	// Suspension also ends every session, which invalidates issued access tokens
+	if user.Suspended {
+		if _, err := service.RefreshTokenServiceInstance.RevokeAllForUser(ctx, user.ID.Hex(), service.RevokeReasonSuspended); err != nil {
+			writeServiceError(w, r, err, "Error revoking sessions")
+			return
+		}
+	}
+
+	action := model.AuditActionUserReinstate
+	if user.Suspended {
+		action = model.AuditActionUserSuspend
+	}
+	recordAudit(r, action, "user", user.ID.Hex(), before, user)
+
+	json.NewEncoder(w).Encode(user)
+}
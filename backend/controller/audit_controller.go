@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+)
+
+// AdminListAudit returns the audit trail of privileged actions
+// Backend: GET /api/admin/audit (admin role)
+// Query params: actor (user ID), target (target ID), action,
+// from / to (RFC 3339, from inclusive, to exclusive),
+// page (default 1), limit (default 50, max 200)
+// Response: { entries: [{ id, actorId, actorEmail, action, targetType, targetId, before, after, requestId, createdAt }], page, limit, total }
+func AdminListAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := model.AuditFilter{
+		ActorID:  query.Get("actor"),
+		TargetID: query.Get("target"),
+		Action:   query.Get("action"),
+	}
+
+	if param := parseTimeRange(query, &filter.From, &filter.To); param != "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, param+" must be an RFC 3339 timestamp")
+		return
+	}
+
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), 50)
+	if limit > 200 {
+		limit = 200
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, total, err := service.AuditServiceInstance.List(ctx, filter, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching audit log")
+		return
+	}
+
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// AdminListLoginAttempts returns recorded login attempts, successful or not
+// Backend: GET /api/admin/login-attempts (admin role)
+// Query params: email, ip, userId, result (success, invalid_credentials,
+// suspended, throttled, locked), from / to (RFC 3339, from inclusive, to exclusive),
+// page (default 1), limit (default 50, max 200)
+// Response: { attempts: [{ id, email, userId, ip, userAgent, result, requestId, createdAt }], page, limit, total }
+func AdminListLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := model.LoginAttemptFilter{
+		Email:  query.Get("email"),
+		IP:     query.Get("ip"),
+		UserID: query.Get("userId"),
+		Result: query.Get("result"),
+	}
+
+	if param := parseTimeRange(query, &filter.From, &filter.To); param != "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, param+" must be an RFC 3339 timestamp")
+		return
+	}
+
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), 50)
+	if limit > 200 {
+		limit = 200
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	attempts, total, err := service.LoginAttemptServiceInstance.List(ctx, filter, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching login attempts")
+		return
+	}
+
+	if attempts == nil {
+		attempts = []model.LoginAttempt{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempts": attempts,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+	})
+}
+
+// parseTimeRange reads the optional from / to query params (RFC 3339)
+// Returns the name of the first invalid param, or "" when both are valid
+func parseTimeRange(query url.Values, from, to **time.Time) string {
+	for param, dest := range map[string]**time.Time{"from": from, "to": to} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return param
+		}
+		*dest = &parsed
+	}
+	return ""
+}
+
+// recordAudit logs a privileged action taken by the caller
+// The actor comes from the claims AuthMiddleware put in the request context.
+// Called after the action succeeded; a failed write is logged but does not
+// fail the request, since the change itself has already been made.
+func recordAudit(r *http.Request, action, targetType, targetID string, before, after interface{}) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	if !ok {
+		log.Printf("request %s: audit %s on %s %s without claims", utils.RequestIDFromContext(r.Context()), action, targetType, targetID)
+		return
+	}
+
+	entry := model.AuditEntry{
+		ActorID:    claims.UserID,
+		ActorEmail: claims.Email,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  utils.RequestIDFromContext(r.Context()),
+	}
+
+	// Detached from the request so a client disconnect cannot drop the entry
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+
+	if err := service.AuditServiceInstance.Record(ctx, entry, before, after); err != nil {
+		log.Printf("request %s: failed to write audit entry %s on %s %s: %v", entry.RequestID, action, targetType, targetID, err)
+	}
+}
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"rewardpage/middleware"
 	"rewardpage/model"
@@ -14,55 +16,279 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Login checks email and password and starts a new session
+// Frontend: POST /api/users/login
+// Request body: { email, password }
+// Response: { access_token, refresh_token }, or with two-factor authentication
+// enabled { mfa_required: true, mfa_token, expires_in } to finish at
+// POST /api/users/login/mfa
+// Failed attempts are counted per account and per client IP: after a few, each
+// further attempt has to wait twice as long, and many in a row lock the
+// account (or IP) for 15 minutes
+// Errors: 401 unauthorized, 403 account_suspended, 429 login_throttled /
+// account_locked with Retry-After and details.remainingSeconds
 func Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var input model.UserInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
+	ip := utils.ClientIP(r)
+
+	// Throttled clients are turned away before the password is looked at
+	if loginThrottled(ctx, w, r, input.Email, "") {
+		return
+	}
+
 	var user model.User
 	err := service.UserServiceInstance.FindUserByEmail(ctx, input.Email, &user)
+	if err != nil && !errors.Is(err, service.ErrUserNotFound) {
+		writeServiceError(w, r, err, "Error logging in")
+		return
+	}
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	}
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		if err := service.LoginAttemptServiceInstance.RecordFailure(ctx, input.Email, ip); err != nil {
+			log.Printf("request %s: recording failed login: %v", utils.RequestIDFromContext(r.Context()), err)
+		}
+		userID := ""
+		if !user.ID.IsZero() {
+			userID = user.ID.Hex()
+		}
+		logLoginAttempt(r, input.Email, userID, model.LoginResultInvalidCredentials)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid email or password")
+		return
+	}
+
+	// Only reported after the password matched so it does not reveal accounts
+	if user.Suspended {
+		logLoginAttempt(r, input.Email, user.ID.Hex(), model.LoginResultSuspended)
+		writeServiceError(w, r, service.ErrAccountSuspended, "Error logging in")
 		return
 	}
 
-	// Compare passwords
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+	// With 2FA the password only earns a challenge. Failed logins are cleared
+	// after the second factor, so the password alone cannot reset the budget
+	// for guessing codes.
+	mfaEnabled, err := service.TOTPServiceInstance.IsEnabled(ctx, user.ID.Hex())
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		writeServiceError(w, r, err, "Error logging in")
+		return
+	}
+	if mfaEnabled {
+		mfaToken, err := newMFAChallenge(r, &user)
+		if err != nil {
+			writeServiceError(w, r, err, "Error generating tokens")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(utils.MFAChallengeTTL / time.Second),
+		})
 		return
 	}
 
-	// Generate JWT token
-	// Updated to include role and generate refresh token for product-ready auth
-	accessToken, err := utils.GenerateToken(user.ID.Hex(), user.Email, user.Role)
+	completeLogin(ctx, w, r, &user)
+}
+
+// LoginMFA finishes a login with two-factor authentication
+// Frontend: POST /api/users/login/mfa
+// Request body: { mfa_token, code } or { mfa_token, recovery_code }
+// Response: { access_token, refresh_token }
+// mfa_token comes from Login and works once, for 5 minutes. Wrong codes count
+// as failed logins of the account, with the same backoff and lockout.
+// Errors: 401 unauthorized (invalid, expired or used mfa_token), 403
+// invalid_mfa_code, 403 account_suspended, 429 login_throttled / account_locked
+func LoginMFA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide the MFA token and a code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	claims, err := utils.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	used, err := service.BlacklistServiceInstance.IsTokenBlacklisted(ctx, claims.ID)
 	if err != nil {
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error logging in")
+		return
+	}
+	if used {
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or expired MFA token")
 		return
 	}
 
-	// Generate refresh token
-	refreshToken, err := utils.GenerateRefreshToken(user.ID.Hex())
+	var user model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, claims.UserID, &user); err != nil {
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "User not found")
+		return
+	}
+
+	if loginThrottled(ctx, w, r, user.Email, user.ID.Hex()) {
+		return
+	}
+	if user.Suspended {
+		logLoginAttempt(r, user.Email, user.ID.Hex(), model.LoginResultSuspended)
+		writeServiceError(w, r, service.ErrAccountSuspended, "Error logging in")
+		return
+	}
+
+	if req.RecoveryCode != "" {
+		err = service.TOTPServiceInstance.UseRecoveryCode(ctx, user.ID.Hex(), req.RecoveryCode)
+	} else {
+		err = service.TOTPServiceInstance.Verify(ctx, user.ID.Hex(), req.Code)
+	}
+	if errors.Is(err, service.ErrInvalidMFACode) {
+		if err := service.LoginAttemptServiceInstance.RecordFailure(ctx, user.Email, utils.ClientIP(r)); err != nil {
+			log.Printf("request %s: recording failed login: %v", utils.RequestIDFromContext(r.Context()), err)
+		}
+		logLoginAttempt(r, user.Email, user.ID.Hex(), model.LoginResultInvalidMFACode)
+	}
 	if err != nil {
-		http.Error(w, "Error generating refresh token", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error logging in")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	// Spend the challenge in one conditional write, so of concurrent requests
+	// with the same token only one signs in
+	spent, err := service.BlacklistServiceInstance.SpendToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+	if err != nil {
+		writeServiceError(w, r, err, "Error logging in")
+		return
+	}
+	if !spent {
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	completeLogin(ctx, w, r, &user)
+}
+
+// newMFAChallenge issues the token a user with 2FA exchanges at POST /api/users/login/mfa
+func newMFAChallenge(r *http.Request, user *model.User) (string, error) {
+	mfaToken, err := utils.GenerateMFAToken(user.ID.Hex())
+	if err != nil {
+		return "", err
+	}
+	logLoginAttempt(r, user.Email, user.ID.Hex(), model.LoginResultMFARequired)
+	return mfaToken, nil
+}
+
+// loginThrottled rejects the request while the account or client IP is backing
+// off or locked; userID is empty when the account is not known yet
+func loginThrottled(ctx context.Context, w http.ResponseWriter, r *http.Request, email, userID string) bool {
+	err := service.LoginAttemptServiceInstance.Check(ctx, email, utils.ClientIP(r))
+	if err == nil {
+		return false
+	}
+
+	result := model.LoginResultThrottled
+	if errors.Is(err, service.ErrAccountLocked) {
+		result = model.LoginResultLocked
+	}
+	logLoginAttempt(r, email, userID, result)
+	writeServiceError(w, r, err, "Error logging in")
+	return true
+}
+
+// completeLogin starts a session for a fully authenticated user
+// Responds with { access_token, refresh_token }
+func completeLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, user *model.User) {
+	tokens, err := startSession(ctx, r, user)
+	if err != nil {
+		writeServiceError(w, r, err, "Error logging in")
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// startSession clears the account's failed logins, logs the login and issues
+// the tokens of a new session
+func startSession(ctx context.Context, r *http.Request, user *model.User) (map[string]string, error) {
+	if err := service.LoginAttemptServiceInstance.RecordSuccess(ctx, user.Email); err != nil {
+		log.Printf("request %s: clearing failed logins: %v", utils.RequestIDFromContext(r.Context()), err)
+	}
+	logLoginAttempt(r, user.Email, user.ID.Hex(), model.LoginResultSuccess)
+
+	// Every login starts a new refresh token family
+	family, err := service.RefreshTokenServiceInstance.StartFamily(ctx, user.ID.Hex(), r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokens(user, family)
+}
+
+// logLoginAttempt stores a login attempt for GET /api/admin/login-attempts
+// userID is empty when no account has the email; a failed write is only logged
+func logLoginAttempt(r *http.Request, email, userID, result string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+
+	err := service.LoginAttemptServiceInstance.Log(ctx, model.LoginAttempt{
+		Email:     email,
+		UserID:    userID,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Result:    result,
+		RequestID: utils.RequestIDFromContext(r.Context()),
 	})
+	if err != nil {
+		log.Printf("request %s: logging login attempt: %v", utils.RequestIDFromContext(r.Context()), err)
+	}
+}
+
+// issueTokens signs an access token and the family's current refresh token
+// Response shape shared by login and refresh: { access_token, refresh_token }
+func issueTokens(user *model.User, family *model.RefreshTokenFamily) (map[string]string, error) {
+	accessToken, err := utils.GenerateToken(user.ID.Hex(), user.Email, user.Role, family.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken(user.ID.Hex(), family.ID, family.CurrentTokenID, family.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
 
+	return map[string]string{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	}, nil
 }
 
-// Added Refresh endpoint for token refresh functionality
+// Refresh exchanges a refresh token for a new access and refresh token
+// Frontend: POST /api/users/refresh
+// Request body: { refresh_token }
+// Response: { access_token, refresh_token } - the old refresh token stops working
+// Errors: 401 invalid_refresh_token, 401 refresh_token_reused (the login is
+// revoked because the token must have leaked)
 func Refresh(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -70,7 +296,7 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
 		return
 	}
 
@@ -80,7 +306,7 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 	// Validate refresh token
 	claims, err := utils.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid refresh token")
 		return
 	}
 
@@ -88,50 +314,112 @@ func Refresh(w http.ResponseWriter, r *http.Request) {
 	var user model.User
 	err = service.UserServiceInstance.FindUserByID(ctx, claims.UserID, &user)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "User not found")
+		return
+	}
+	if user.Suspended {
+		writeServiceError(w, r, service.ErrAccountSuspended, "Error refreshing token")
+		return
+	}
+
+	// Rotate: the presented token is spent, a replay of it revokes the family
+	family, err := service.RefreshTokenServiceInstance.Rotate(ctx, claims.UserID, claims.FamilyID, claims.ID, utils.ClientIP(r))
+	if err != nil {
+		writeServiceError(w, r, err, "Error refreshing token")
 		return
 	}
 
-	// Generate new access token
-	accessToken, err := utils.GenerateToken(claims.UserID, user.Email, user.Role)
+	tokens, err := issueTokens(&user, family)
 	if err != nil {
-		http.Error(w, "Error generating access token", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error generating tokens")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"access_token": accessToken,
-	})
+	json.NewEncoder(w).Encode(tokens)
 }
 
-// Added Logout endpoint for token blacklisting
+// Logout ends the current login
+// Frontend: POST /api/users/logout (authenticated)
+// Blacklists the access token and revokes its refresh token family
 func Logout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		http.Error(w, "Authorization header missing or invalid", http.StatusBadRequest)
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Blacklist the token
+	if err := blacklistAccessToken(ctx, claims); err != nil {
+		writeServiceError(w, r, err, "Error logging out")
 		return
 	}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if claims.SessionID != "" {
+		if err := service.RefreshTokenServiceInstance.RevokeFamily(ctx, claims.UserID, claims.SessionID); err != nil {
+			writeServiceError(w, r, err, "Error logging out")
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll ends every login of the current user, on all devices
+// Frontend: POST /api/users/logout-all (authenticated)
+// Response: { message, sessionsRevoked }
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
 
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	// Blacklist the token
-	err := service.BlacklistServiceInstance.BlacklistToken(ctx, token)
+	revoked, err := service.RefreshTokenServiceInstance.RevokeAllForUser(ctx, claims.UserID, service.RevokeReasonLogoutAll)
 	if err != nil {
-		http.Error(w, "Error logging out", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error logging out")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Logged out successfully",
+	if err := blacklistAccessToken(ctx, claims); err != nil {
+		writeServiceError(w, r, err, "Error logging out")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Logged out of all sessions",
+		"sessionsRevoked": revoked,
 	})
 }
 
+// GetJWKS publishes the public keys tokens are signed with (JSON Web Key Set)
+// Backend: GET /.well-known/jwks.json (public)
+// Response: { keys: [{ kty, kid, alg, use, crv, x } or { kty, kid, alg, use, n, e }] }
+// Includes the next key shortly before a rotation and retired keys until
+// their tokens expire; match a token's kid header against these
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	json.NewEncoder(w).Encode(utils.JWKS())
+}
+
+// blacklistAccessToken revokes the access token described by claims until it expires
+// Tokens issued before access tokens carried a jti cannot be blacklisted; they
+// expire within 15 minutes
+func blacklistAccessToken(ctx context.Context, claims *utils.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return service.BlacklistServiceInstance.BlacklistToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
 // Added Me endpoint to get logged-in user details
+// Frontend: GET /api/users/me (authenticated); GET /api/users/profile is an alias
+// Response: the user without secrets (toUserOutput)
 func Me(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -143,16 +431,70 @@ func Me(w http.ResponseWriter, r *http.Request) {
 	var user model.User
 	err := service.UserServiceInstance.FindUserByID(ctx, claims.UserID, &user)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeServiceError(w, r, err, "Error fetching user")
+		return
+	}
+
+	json.NewEncoder(w).Encode(toUserOutput(&user))
+}
+
+// UpdateMe changes the logged-in user's own profile
+// Frontend: PATCH /api/users/me (authenticated)
+// Request body: any of { username, displayName, avatarUrl, timezone, email,
+// currentPassword } - omitted fields stay unchanged, other fields are rejected
+// Response: the updated profile, as GET /api/users/me
+// A new email needs currentPassword (an account created through an identity
+// provider sets one with the forgot password flow first); the account stops
+// earning points until the link emailed to the new address is followed
+// The timezone can be changed once a week (it moves daily resets and check-in days)
+// Errors: 400 validation_failed / invalid_timezone, 403 wrong_password,
+// 409 email_exists / password_not_set, 429 timezone_change_too_soon (with Retry-After)
+func UpdateMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var input model.ProfileUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		message := "Invalid request body"
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			message = "Only username, displayName, avatarUrl, timezone and email can be changed"
+		}
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, message)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, emailChanged, err := service.UserServiceInstance.UpdateProfile(ctx, claims.UserID, input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating profile")
 		return
 	}
 
-	userOutput := model.UserOutput{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+	if emailChanged {
+		if err := service.EmailVerificationServiceInstance.SendVerification(ctx, user); err != nil {
+			// Non-blocking error - the user can ask for a new link through POST /api/users/verify/resend
+			log.Printf("Warning: Failed to send verification email for user %s: %v", user.ID.Hex(), err)
+		}
 	}
 
-	json.NewEncoder(w).Encode(userOutput)
+	json.NewEncoder(w).Encode(toUserOutput(user))
+}
+
+// toUserOutput is the profile returned to the user themselves
+func toUserOutput(user *model.User) model.UserOutput {
+	return model.UserOutput{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		Role:          user.Role,
+		Timezone:      user.Timezone,
+		EmailVerified: user.EmailVerified,
+		DisplayName:   user.DisplayName,
+		AvatarURL:     user.AvatarURL,
+	}
 }
@@ -2,118 +2,90 @@ package controller
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"rewardpage/model"
 	"rewardpage/service"
+	"rewardpage/utils"
 
 	"github.com/gorilla/mux"
 )
 
-// GetAlluser retrieves all users from the database
-func GetAlluser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	users, err := service.UserServiceInstance.GetAllUsers(r.Context())
-	if err != nil {
-		http.Error(w, "Error fetching users", http.StatusInternalServerError)
-		return
-	}
-
-	json.NewEncoder(w).Encode(users)
-}
-
-// Get1user retrieves a single user by ID
-func Get1user(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	vars := mux.Vars(r)
-	userID := vars["id"]
-
-	user, err := service.UserServiceInstance.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	json.NewEncoder(w).Encode(user)
-}
-
 // Create1user creates a new user
+// Request body: { username, email, password, referralCode? }
+// A verification link is emailed; the account earns points once it is verified
+// When referralCode is given the referrer is credited ReferralBonusPoints after
+// the new user verifies their email
 func Create1user(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var user model.UserInput
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
 		return
 	}
 
 	// Validate required fields
 	if user.Username == "" || user.Email == "" || user.Password == "" {
-		http.Error(w, "Please provide all required fields", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide all required fields")
 		return
 	}
 
-	err := service.UserServiceInstance.CreateUser(r.Context(), user)
+	// Resolve the referrer before creating the account so a bad code,
+	// self-referral or reused mailbox fails the whole registration
+	var referrer *model.User
+	if user.ReferralCode != "" {
+		var err error
+		referrer, err = service.ReferralServiceInstance.ResolveReferrer(r.Context(), user.ReferralCode, user.Email)
+		if err != nil {
+			writeServiceError(w, r, err, "Error checking referral code")
+			return
+		}
+	}
+
+	created, err := service.UserServiceInstance.CreateUser(r.Context(), user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeServiceError(w, r, err, "Error creating user")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User created successfully"})
-}
-
-// Update1user updates an existing user
-func Update1user(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	vars := mux.Vars(r)
-	userID := vars["id"]
-
-	var updateData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if referrer != nil {
+		if _, err := service.ReferralServiceInstance.Attribute(r.Context(), referrer, created); err != nil {
+			// Non-blocking error - the account exists, only the referral bonus is missing
+			log.Printf("Warning: Failed to attribute referral for user %s: %v", created.ID.Hex(), err)
+		}
 	}
 
-	err := service.UserServiceInstance.UpdateUser(r.Context(), userID, updateData)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if err := service.EmailVerificationServiceInstance.SendVerification(r.Context(), created); err != nil {
+		// Non-blocking error - the user can ask for a new link through POST /api/users/verify/resend
+		log.Printf("Warning: Failed to send verification email for user %s: %v", created.ID.Hex(), err)
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "User updated successfully"})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User created successfully; check your email to verify your address"})
 }
 
 // Delete1user deletes a single user by ID
+// Backend: DELETE /api/admin/users/{id} (admin role)
 func Delete1user(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
 	userID := vars["id"]
 
-	err := service.UserServiceInstance.DeleteUser(r.Context(), userID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	var before model.User
+	if err := service.UserServiceInstance.FindUserByID(r.Context(), userID, &before); err != nil {
+		writeServiceError(w, r, err, "Error deleting user")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
-}
-
-// DeleteAlluser deletes all users from the database
-func DeleteAlluser(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	count, err := service.UserServiceInstance.DeleteAllUsers(r.Context())
+	err := service.UserServiceInstance.DeleteUser(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Error deleting users", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error deleting user")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "All users deleted successfully",
-		"count":   count,
-	})
+	recordAudit(r, model.AuditActionUserDelete, "user", userID, before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted successfully"})
 }
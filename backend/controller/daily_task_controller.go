@@ -13,15 +13,17 @@ import (
 // CHANGE: GetDailyTasks handles GET /api/tasks/daily endpoint
 //
 //	Returns: {
-//	  tasks: [{ id, number, completed, completedAt }],
+//	  tasks: [{ id, number, title, description, points, box, completed, completedAt }],
 //	  completedCount: number,
 //	  lastCompletedAt: timestamp,
-//	  nextResetAt: timestamp,
+//	  nextResetAt: timestamp (UTC),
+//	  nextResetAtLocal: timestamp (user's timezone offset),
+//	  timezone: string,
 //	  cooldownUntil: timestamp
 //	}
 //
 // Frontend uses this to:
-// 1. Load 5 task buttons at page load
+// 1. Load the day's task buttons at page load
 // 2. Show completion status (completed, disabled, available)
 // 3. Show cooldown timer if active
 func GetDailyTasks(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +32,7 @@ func GetDailyTasks(w http.ResponseWriter, r *http.Request) {
 	// CHANGE: Extract user ID from JWT token (set by auth middleware)
 	userID, err := utils.ExtractUserIDFromToken(r)
 	if err != nil {
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -45,27 +47,31 @@ func GetDailyTasks(w http.ResponseWriter, r *http.Request) {
 		// Don't return error - continue with existing tasks
 	}
 
-	// CHANGE: Get or create today's 5 tasks (auto-creates if first time today)
+	// CHANGE: Get or create today's tasks (auto-creates from templates if first time today)
 	tasks, err := service.DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID)
 	if err != nil {
-		http.Error(w, `{"error":"failed to load tasks"}`, http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load tasks")
 		return
 	}
 
 	// CHANGE: Get progress to return cooldown and completion count
 	progress, err := service.DailyTaskServiceInstance.GetOrCreateProgress(ctx, userID)
 	if err != nil {
-		http.Error(w, `{"error":"failed to load progress"}`, http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load progress")
 		return
 	}
 
 	// CHANGE: Return tasks with progress metadata to frontend
+	// nextResetAt is given in UTC and in the user's own timezone
+	loc := service.UserLocation(ctx, userID)
 	response := map[string]interface{}{
-		"tasks":           tasks,
-		"completedCount":  progress.CompletedCount,
-		"lastCompletedAt": progress.LastCompletedAt,
-		"nextResetAt":     progress.NextResetAt,
-		"cooldownUntil":   progress.LastCooldownEnd,
+		"tasks":            tasks,
+		"completedCount":   progress.CompletedCount,
+		"lastCompletedAt":  progress.LastCompletedAt,
+		"nextResetAt":      progress.NextResetAt.UTC(),
+		"nextResetAtLocal": progress.NextResetAt.In(loc).Format(time.RFC3339),
+		"timezone":         loc.String(),
+		"cooldownUntil":    progress.LastCooldownEnd,
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -76,15 +82,20 @@ func GetDailyTasks(w http.ResponseWriter, r *http.Request) {
 //
 //	Response: {
 //	  success: boolean,
-//	  task: { id, number, completed, completedAt },
+//	  task: { id, number, title, points, completed, completedAt },
 //	  completedCount: number,
-//	  nextResetAt: timestamp,
-//	  cooldownUntil: timestamp
+//	  nextResetAt: timestamp (UTC),
+//	  nextResetAtLocal: timestamp (user's timezone offset),
+//	  timezone: string,
+//	  cooldownUntil: timestamp,
+//	  pointsAwarded: number
 //	}
 //
 // Validation performed server-side:
-// - User not in cooldown (5 minutes since last task)
-// - User hasn't completed 5 tasks today
+// - Task belongs to the user and to today's tasks (404 otherwise)
+// - Task not already completed (409)
+// - User not in cooldown (5 minutes since last task) (429 with Retry-After)
+// - User hasn't completed all of today's tasks (409)
 // - Daily reset check (if past midnight)
 func CompleteTaskDaily(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -92,20 +103,20 @@ func CompleteTaskDaily(w http.ResponseWriter, r *http.Request) {
 	// CHANGE: Extract user ID from JWT token
 	userID, err := utils.ExtractUserIDFromToken(r)
 	if err != nil {
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	// CHANGE: Parse request body for taskId
 	var input map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, `{"error":"invalid request"}`, http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request")
 		return
 	}
 
 	taskID := input["taskId"]
 	if taskID == "" {
-		http.Error(w, `{"error":"task_id required"}`, http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Task_id required")
 		return
 	}
 
@@ -117,33 +128,28 @@ func CompleteTaskDaily(w http.ResponseWriter, r *http.Request) {
 	result, err := service.DailyTaskServiceInstance.CompleteTask(ctx, userID, taskID)
 
 	if err != nil {
-		log.Printf("ERROR - CompleteTask failed: userID=%s, taskID=%s, error=%v, type=%T", userID, taskID, err, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-			"debug": err.Error(), // Return full error to frontend for debugging
-		})
+		writeServiceError(w, r, err, "Error completing task")
 		return
 	}
 
-	// CHANGE: Award points to user for task completion (20 points per task)
-	// This updates the leaderboard in real-time
-	const POINTS_PER_TASK = 20
-	if err := service.LeaderboardServiceInstance.AddPointsToUser(ctx, userID, POINTS_PER_TASK); err != nil {
-		log.Printf("Warning: Failed to add points to user %s: %v", userID, err)
-		// Non-blocking error - task still completed, just points not updated
-	}
+	// CHANGE: The task's points (set by its task template) were awarded through
+	// the points ledger as part of the completion; the leaderboard is updated in real-time
+	pointsAwarded, _ := result["points"].(int)
 
 	// CHANGE: Success response must include success flag
+	nextResetAt, _ := result["next_reset_at"].(time.Time)
+	loc := service.UserLocation(ctx, userID)
 	response := map[string]interface{}{
-		"success":        true,
-		"task":           result["task"],
-		"completedCount": result["completed_count"],
-		"nextResetAt":    result["next_reset_at"],
-		"cooldownUntil":  result["cooldown_until"],
-		"pointsAwarded":  POINTS_PER_TASK,
-	}
-	log.Printf("Task completed successfully: userID=%s, taskID=%s, pointsAwarded=%d", userID, taskID, POINTS_PER_TASK)
+		"success":          true,
+		"task":             result["task"],
+		"completedCount":   result["completed_count"],
+		"nextResetAt":      nextResetAt.UTC(),
+		"nextResetAtLocal": nextResetAt.In(loc).Format(time.RFC3339),
+		"timezone":         loc.String(),
+		"cooldownUntil":    result["cooldown_until"],
+		"pointsAwarded":    pointsAwarded,
+	}
+	log.Printf("Task completed successfully: userID=%s, taskID=%s, pointsAwarded=%d", userID, taskID, pointsAwarded)
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -165,17 +171,23 @@ func CheckCooldown(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := utils.ExtractUserIDFromToken(r)
 	if err != nil {
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	ctx, cancel := utils.CreateContext()
 	defer cancel()
 
+	// CHANGE: Roll the day over first so a cooldown never carries past the
+	// user's own midnight
+	if err := service.DailyTaskServiceInstance.CheckAndResetDaily(ctx, userID); err != nil {
+		log.Printf("Warning: CheckAndResetDaily failed: %v", err)
+	}
+
 	// CHANGE: Get current progress to check cooldown status
 	progress, err := service.DailyTaskServiceInstance.GetOrCreateProgress(ctx, userID)
 	if err != nil {
-		http.Error(w, `{"error":"failed to check cooldown"}`, http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to check cooldown")
 		return
 	}
 
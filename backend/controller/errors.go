@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"strconv"
+)
+
+// retryAfterError is implemented by errors that say how long the client should
+// wait (*service.DailyTaskCooldownError, *service.LoginThrottledError,
+// *service.TimezoneCooldownError)
+type retryAfterError interface {
+	RemainingSeconds() int
+}
+
+// serviceError maps a service-layer sentinel error to an HTTP status and code
+type serviceError struct {
+	err    error
+	status int
+	code   string
+}
+
+// serviceErrors is the single table of service errors the API exposes
+// The error's own message is sent to the client; anything not listed here is
+// logged and answered with a generic 500 so internal details never leak.
+var serviceErrors = []serviceError{
+	// Users
+	{service.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{service.ErrEmailExists, http.StatusConflict, "email_exists"},
+	{service.ErrInvalidTimezone, http.StatusBadRequest, "invalid_timezone"},
+	{service.ErrTimezoneChangeTooSoon, http.StatusTooManyRequests, "timezone_change_too_soon"},
+	{service.ErrInvalidProfile, http.StatusBadRequest, utils.CodeValidation},
+	{service.ErrAccountSuspended, http.StatusForbidden, "account_suspended"},
+	{service.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{service.ErrSelfModification, http.StatusConflict, "cannot_modify_self"},
+	{service.ErrRefreshTokenInvalid, http.StatusUnauthorized, "invalid_refresh_token"},
+	{service.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{service.ErrSessionNotFound, http.StatusNotFound, "session_not_found"},
+	{service.ErrSessionRevoked, http.StatusUnauthorized, "session_revoked"},
+	{service.ErrWeakPassword, http.StatusBadRequest, "weak_password"},
+	{service.ErrWrongPassword, http.StatusForbidden, "wrong_password"},
+	{service.ErrPasswordNotSet, http.StatusConflict, "password_not_set"},
+	{service.ErrResetTokenInvalid, http.StatusBadRequest, "invalid_reset_token"},
+	{service.ErrEmailNotVerified, http.StatusForbidden, "email_not_verified"},
+	{service.ErrVerificationTokenInvalid, http.StatusBadRequest, "invalid_verification_token"},
+	{service.ErrEmailAlreadyVerified, http.StatusConflict, "email_already_verified"},
+	{service.ErrVerificationResendTooSoon, http.StatusTooManyRequests, "verification_resend_too_soon"},
+	{service.ErrLoginThrottled, http.StatusTooManyRequests, "login_throttled"},
+	{service.ErrAccountLocked, http.StatusTooManyRequests, "account_locked"},
+	{service.ErrTOTPAlreadyEnabled, http.StatusConflict, "mfa_already_enabled"},
+	{service.ErrTOTPNotEnrolled, http.StatusConflict, "mfa_not_enrolled"},
+	{service.ErrTOTPNotEnabled, http.StatusConflict, "mfa_not_enabled"},
+	{service.ErrInvalidMFACode, http.StatusForbidden, "invalid_mfa_code"},
+	{service.ErrOIDCProviderNotFound, http.StatusNotFound, "oidc_provider_not_found"},
+	{service.ErrOIDCStateInvalid, http.StatusBadRequest, "oidc_state_invalid"},
+	{service.ErrOIDCEmailNotVerified, http.StatusForbidden, "oidc_email_not_verified"},
+	{service.ErrOIDCAccountUnverified, http.StatusConflict, "oidc_account_unverified"},
+	{service.ErrOIDCLoginFailed, http.StatusBadGateway, "oidc_login_failed"},
+
+	// Points
+	{service.ErrInsufficientPoints, http.StatusPaymentRequired, "insufficient_points"},
+	{service.ErrAlreadyAwarded, http.StatusConflict, "already_awarded"},
+	{service.ErrInvalidPointsAdjustment, http.StatusBadRequest, utils.CodeValidation},
+
+	// Daily tasks
+	{service.ErrDailyTaskNotFound, http.StatusNotFound, "task_not_found"},
+	{service.ErrDailyTaskAlreadyCompleted, http.StatusConflict, "task_already_completed"},
+	{service.ErrDailyTaskCooldown, http.StatusTooManyRequests, "cooldown_active"},
+	{service.ErrDailyTaskLimitReached, http.StatusConflict, "daily_limit_reached"},
+	{service.ErrTaskTemplateNotFound, http.StatusNotFound, "task_template_not_found"},
+	{service.ErrInvalidTaskTemplateInput, http.StatusBadRequest, utils.CodeValidation},
+
+	// Rewards
+	{service.ErrRewardNotFound, http.StatusNotFound, "reward_not_found"},
+	{service.ErrRedemptionNotFound, http.StatusNotFound, "redemption_not_found"},
+	{service.ErrRewardOutOfStock, http.StatusConflict, "reward_out_of_stock"},
+	{service.ErrRewardUnavailable, http.StatusConflict, "reward_unavailable"},
+	{service.ErrRedemptionLimitReached, http.StatusConflict, "redemption_limit_reached"},
+	{service.ErrInvalidStatusTransition, http.StatusConflict, "invalid_status_transition"},
+	{service.ErrInvalidRewardInput, http.StatusBadRequest, utils.CodeValidation},
+
+	// Referrals
+	{service.ErrInvalidReferralCode, http.StatusBadRequest, "invalid_referral_code"},
+	{service.ErrSelfReferral, http.StatusBadRequest, "self_referral"},
+	{service.ErrReferralEmailUsed, http.StatusConflict, "referral_email_used"},
+
+	// Streaks
+	{service.ErrStreakFreezeLimit, http.StatusConflict, "streak_freeze_limit"},
+	{service.ErrStreakNotBroken, http.StatusConflict, "streak_not_broken"},
+	{service.ErrStreakRepairExpired, http.StatusConflict, "streak_repair_expired"},
+	{service.ErrStreakMilestoneNotFound, http.StatusNotFound, "streak_milestone_not_found"},
+	{service.ErrStreakMilestoneExists, http.StatusConflict, "streak_milestone_exists"},
+	{service.ErrInvalidStreakMilestoneInput, http.StatusBadRequest, utils.CodeValidation},
+}
+
+// writeError sends the standard error envelope without details
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	utils.WriteError(w, r, status, code, message, nil)
+}
+
+// writeServiceError maps a service error to the standard error envelope
+// fallback is the message used for unexpected (500) errors
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	if mapped, ok := lookupServiceError(err); ok {
+		var details map[string]interface{}
+		var wait retryAfterError
+		if errors.As(err, &wait) {
+			details = map[string]interface{}{"remainingSeconds": wait.RemainingSeconds()}
+			w.Header().Set("Retry-After", strconv.Itoa(wait.RemainingSeconds()))
+		}
+
+		utils.WriteError(w, r, mapped.status, mapped.code, err.Error(), details)
+		return
+	}
+
+	log.Printf("request %s: %s: %v", utils.RequestIDFromContext(r.Context()), fallback, err)
+	writeError(w, r, http.StatusInternalServerError, utils.CodeInternal, fallback)
+}
+
+// lookupServiceError finds the serviceErrors entry matching err
+func lookupServiceError(err error) (serviceError, bool) {
+	for _, mapped := range serviceErrors {
+		if errors.Is(err, mapped.err) {
+			return mapped, true
+		}
+	}
+	return serviceError{}, false
+}
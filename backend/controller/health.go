@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"rewardpage/service"
 	"rewardpage/utils"
@@ -14,8 +15,9 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	err := service.GetDB().Client().Ping(ctx, nil)
 	if err != nil {
+		log.Printf("request %s: health check ping failed: %v", utils.RequestIDFromContext(r.Context()), err)
 		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{"status": "db_error", "error": err.Error()})
+		json.NewEncoder(w).Encode(map[string]string{"status": "db_error"})
 		return
 	}
 
@@ -44,7 +44,7 @@ func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 
 	leaderboard, err := service.LeaderboardServiceInstance.GetLeaderboard(ctx, limit)
 	if err != nil {
-		http.Error(w, `{"error":"Error fetching leaderboard"}`, http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error fetching leaderboard")
 		return
 	}
 
@@ -75,7 +75,7 @@ func GetUserRank(w http.ResponseWriter, r *http.Request) {
 
 	userRank, err := service.LeaderboardServiceInstance.GetUserRank(ctx, userID)
 	if err != nil {
-		http.Error(w, "Error fetching user rank", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error fetching user rank")
 		return
 	}
 
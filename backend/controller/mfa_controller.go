@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+)
+
+// GetMFAStatus tells whether the logged-in user has two-factor authentication on
+// Frontend: GET /api/users/mfa (authenticated)
+// Response: { totpEnabled, recoveryCodesRemaining }
+func GetMFAStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	enabled, remaining, err := service.TOTPServiceInstance.Status(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching two-factor status")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"totpEnabled":            enabled,
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// EnrollTOTP starts setting up an authenticator app
+// Frontend: POST /api/users/mfa/totp (authenticated)
+// Request body: { password }
+// Response: { secret, provisioningUri } - render provisioningUri as a QR code,
+// show secret for manual entry, then confirm with POST /api/users/mfa/totp/verify
+// Calling it again before confirming replaces the secret
+// Errors: 403 wrong_password, 409 mfa_already_enabled / password_not_set
+func EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// A stolen access token alone must not be able to lock the owner out
+	if err := service.UserServiceInstance.CheckPassword(ctx, claims.UserID, req.Password); err != nil {
+		writeServiceError(w, r, err, "Error starting two-factor enrollment")
+		return
+	}
+
+	secret, uri, err := service.TOTPServiceInstance.Enroll(ctx, claims.UserID, claims.Email)
+	if err != nil {
+		writeServiceError(w, r, err, "Error starting two-factor enrollment")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":          secret,
+		"provisioningUri": uri,
+	})
+}
+
+// ConfirmTOTP turns two-factor authentication on with a first code from the app
+// Frontend: POST /api/users/mfa/totp/verify (authenticated)
+// Request body: { code }
+// Response: { message, recoveryCodes } - the recovery codes are shown only
+// this once; each one can replace a code from the app for one login
+// Errors: 403 invalid_mfa_code, 409 mfa_not_enrolled, 409 mfa_already_enabled
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide the code from your authenticator app")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	codes, err := service.TOTPServiceInstance.ConfirmEnrollment(ctx, claims.UserID, req.Code)
+	if err != nil {
+		writeServiceError(w, r, err, "Error enabling two-factor authentication")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTOTP turns two-factor authentication off
+// Frontend: POST /api/users/mfa/totp/disable (authenticated)
+// Request body: { password, code } or { password, recoveryCode }
+// Errors: 403 wrong_password, 403 invalid_mfa_code, 409 mfa_not_enabled
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide a code from your authenticator app or a recovery code")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := service.UserServiceInstance.CheckPassword(ctx, claims.UserID, req.Password); err != nil {
+		writeServiceError(w, r, err, "Error disabling two-factor authentication")
+		return
+	}
+
+	var err error
+	if req.RecoveryCode != "" {
+		err = service.TOTPServiceInstance.UseRecoveryCode(ctx, claims.UserID, req.RecoveryCode)
+	} else {
+		err = service.TOTPServiceInstance.Verify(ctx, claims.UserID, req.Code)
+	}
+	if err != nil {
+		writeServiceError(w, r, err, "Error disabling two-factor authentication")
+		return
+	}
+
+	if err := service.TOTPServiceInstance.Disable(ctx, claims.UserID); err != nil {
+		writeServiceError(w, r, err, "Error disabling two-factor authentication")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetOIDCProviders lists the identity providers users can sign in with
+// Frontend: GET /api/auth/oidc/providers
+// Response: { providers: ["google", ...] } - show one "Sign in with" button
+// per provider, linking to /api/auth/oidc/{provider}/login
+func GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string][]string{
+		"providers": service.OIDCServiceInstance.Providers(),
+	})
+}
+
+// OIDCLogin starts a login with an external identity provider
+// Frontend: navigate the browser to GET /api/auth/oidc/{provider}/login
+// Redirects (302) to the provider, which sends the user back to OIDCCallback
+// Errors: 404 oidc_provider_not_found, 502 oidc_login_failed (provider unreachable)
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, err := service.OIDCServiceInstance.Start(ctx, mux.Vars(r)["provider"])
+	if errors.Is(err, service.ErrOIDCLoginFailed) {
+		log.Printf("request %s: %v", utils.RequestIDFromContext(r.Context()), err)
+		err = service.ErrOIDCLoginFailed // The provider's details stay in the log
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeServiceError(w, r, err, "Error starting login")
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes a login with an external identity provider
+// Backend: GET /api/auth/oidc/{provider}/callback?code=...&state=... (the
+// provider's redirect, registered with it as the redirect URL)
+// The account linked to the provider account is used; otherwise the account
+// with the provider's verified email, which is created when there is none.
+// Redirects to the frontend page /login/oidc with the result in the fragment:
+//   - #access_token=...&refresh_token=...
+//   - #mfa_required=true&mfa_token=...&expires_in=300 with 2FA enabled, to
+//     finish at POST /api/users/login/mfa
+//   - #error=<code>&error_description=<message>, codes as in the JSON API
+//     (oidc_state_invalid, oidc_email_not_verified, oidc_account_unverified,
+//     oidc_login_failed, account_suspended, ...)
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := oidcCallbackResult(ctx, r)
+	if err != nil {
+		code, message := utils.CodeInternal, "Error logging in"
+		if mapped, ok := lookupServiceError(err); ok {
+			code, message = mapped.code, mapped.err.Error()
+		}
+		if code == utils.CodeInternal || errors.Is(err, service.ErrOIDCLoginFailed) {
+			log.Printf("request %s: OIDC login: %v", utils.RequestIDFromContext(r.Context()), err)
+		}
+		result = url.Values{"error": {code}, "error_description": {message}}
+	}
+
+	http.Redirect(w, r, service.OIDCCompletionURL(result), http.StatusFound)
+}
+
+// oidcCallbackResult signs the user in and returns the fragment for the frontend
+func oidcCallbackResult(ctx context.Context, r *http.Request) (url.Values, error) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		// e.g. access_denied when the user cancels at the provider
+		return nil, fmt.Errorf("%w: provider returned %s", service.ErrOIDCLoginFailed, providerErr)
+	}
+
+	user, err := service.OIDCServiceInstance.Complete(ctx, mux.Vars(r)["provider"], query.Get("state"), query.Get("code"))
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Suspended {
+		logLoginAttempt(r, user.Email, user.ID.Hex(), model.LoginResultSuspended)
+		return nil, service.ErrAccountSuspended
+	}
+
+	// The provider replaces the password, not the second factor
+	mfaEnabled, err := service.TOTPServiceInstance.IsEnabled(ctx, user.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		mfaToken, err := newMFAChallenge(r, user)
+		if err != nil {
+			return nil, err
+		}
+		return url.Values{
+			"mfa_required": {"true"},
+			"mfa_token":    {mfaToken},
+			"expires_in":   {strconv.Itoa(int(utils.MFAChallengeTTL / time.Second))},
+		}, nil
+	}
+
+	tokens, err := startSession(ctx, r, user)
+	if err != nil {
+		return nil, err
+	}
+	return url.Values{
+		"access_token":  {tokens["access_token"]},
+		"refresh_token": {tokens["refresh_token"]},
+	}, nil
+}
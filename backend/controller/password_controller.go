@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"strings"
+	"time"
+)
+
+// ForgotPassword emails a password reset link
+// Frontend: POST /api/users/password/forgot
+// Request body: { email }
+// Response: 202 { message } - the same whether or not the email has an account
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide an email")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := service.PasswordResetServiceInstance.RequestReset(ctx, req.Email); err != nil {
+		writeServiceError(w, r, err, "Error requesting password reset")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account exists for this email, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset email
+// Frontend: POST /api/users/password/reset
+// Request body: { token, newPassword }
+// Every session of the account is signed out; the user logs in again
+// Errors: 400 invalid_reset_token, 400 weak_password
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide the reset token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := service.PasswordResetServiceInstance.ResetPassword(ctx, req.Token, req.NewPassword); err != nil {
+		writeServiceError(w, r, err, "Error resetting password")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset; please log in"})
+}
+
+// ChangePassword replaces the logged-in user's password
+// Frontend: POST /api/users/password/change (authenticated)
+// Request body: { currentPassword, newPassword }
+// Response: { message, sessionsRevoked } - other devices are signed out,
+// the session making the request stays logged in
+// Errors: 403 wrong_password, 409 password_not_set, 400 weak_password
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := service.UserServiceInstance.ChangePassword(ctx, claims.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		writeServiceError(w, r, err, "Error changing password")
+		return
+	}
+
+	revoked, err := service.RefreshTokenServiceInstance.RevokeOtherSessions(ctx, claims.UserID, claims.SessionID, service.RevokeReasonPasswordChange)
+	if err != nil {
+		writeServiceError(w, r, err, "Error signing out other sessions")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Password changed successfully",
+		"sessionsRevoked": revoked,
+	})
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"strconv"
+	"time"
+)
+
+// GetPointsHistory returns the logged-in user's points ledger, newest first
+// Frontend: GET /api/points/history (authenticated)
+// Query params: page (optional, default 1), limit (optional, default 20, max 100)
+// Response: { transactions: [{ id, userId, amount, reason, sourceId, createdAt }], page, limit, total }
+func GetPointsHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	userID := claims.UserID
+
+	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
+	limit := parsePositiveInt(r.URL.Query().Get("limit"), 20)
+	if limit > 100 {
+		limit = 100 // Cap at 100 to prevent large transfers
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	transactions, total, err := service.LedgerServiceInstance.GetHistory(ctx, userID, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching points history")
+		return
+	}
+
+	// Return empty array if no entries (instead of null)
+	if transactions == nil {
+		transactions = []model.PointsTransaction{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transactions": transactions,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+	})
+}
+
+// parsePositiveInt parses a query parameter, falling back to def when missing or invalid
+func parsePositiveInt(value string, def int64) int64 {
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		return def
+	}
+	return parsed
+}
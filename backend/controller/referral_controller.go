@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+)
+
+// GetReferrals returns the logged-in user's referral code and stats
+// Frontend: GET /api/referrals (authenticated)
+// Response: { referralCode, referralCount, pointsEarned, pendingReferrals, pointsPerReferral }
+// Called by referPoints.jsx to fill the "Refer and Earn" card
+func GetReferrals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	stats, err := service.ReferralServiceInstance.GetStats(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching referrals")
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
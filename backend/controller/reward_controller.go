@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetRewards returns the active reward catalog
+// Frontend: GET /api/rewards (authenticated)
+// Response: array of { id, title, description, pointCost, stock, perUserLimit, active }
+// Called by the "Redeem Rewards" tab in rewardpage.jsx
+func GetRewards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rewards, err := service.RewardServiceInstance.ListRewards(ctx, true)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching rewards")
+		return
+	}
+
+	// Return empty array if no rewards (instead of null)
+	if rewards == nil {
+		rewards = []model.Reward{}
+	}
+
+	json.NewEncoder(w).Encode(rewards)
+}
+
+// RedeemReward spends the logged-in user's points on a reward
+// Frontend: POST /api/rewards/{id}/redeem (authenticated)
+// Request body: {} (cost is taken from the catalog)
+// Response: 201 { id, userId, rewardId, rewardTitle, pointCost, status: "pending", createdAt }
+// Errors: 404 unknown reward, 409 out of stock / limit reached / unavailable, 402 insufficient points
+func RedeemReward(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	rewardID := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	redemption, err := service.RewardServiceInstance.Redeem(ctx, claims.UserID, rewardID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error redeeming reward")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(redemption)
+}
+
+// GetMyRedemptions returns the logged-in user's redemption history
+// Frontend: GET /api/rewards/redemptions (authenticated)
+// Response: array of redemptions, newest first
+func GetMyRedemptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	redemptions, err := service.RewardServiceInstance.GetRedemptionsByUserID(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching redemptions")
+		return
+	}
+
+	if redemptions == nil {
+		redemptions = []model.Redemption{}
+	}
+
+	json.NewEncoder(w).Encode(redemptions)
+}
+
+// AdminListRewards returns the full catalog including inactive items
+// Backend: GET /api/admin/rewards (admin role)
+func AdminListRewards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rewards, err := service.RewardServiceInstance.ListRewards(ctx, false)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching rewards")
+		return
+	}
+
+	if rewards == nil {
+		rewards = []model.Reward{}
+	}
+
+	json.NewEncoder(w).Encode(rewards)
+}
+
+// AdminCreateReward adds a catalog item
+// Backend: POST /api/admin/rewards (admin role)
+// Request body: { title, description, pointCost, stock, perUserLimit, active }
+// Response: 201 created reward
+func AdminCreateReward(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.RewardInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	reward, err := service.RewardServiceInstance.CreateReward(ctx, input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error creating reward")
+		return
+	}
+
+	recordAudit(r, model.AuditActionRewardCreate, "reward", reward.ID.Hex(), nil, reward)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reward)
+}
+
+// AdminUpdateReward replaces a catalog item's editable fields
+// Backend: PUT /api/admin/rewards/{id} (admin role)
+// Request body: { title, description, pointCost, stock, perUserLimit, active }
+func AdminUpdateReward(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.RewardInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.RewardServiceInstance.GetReward(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating reward")
+		return
+	}
+
+	reward, err := service.RewardServiceInstance.UpdateReward(ctx, before.ID.Hex(), input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating reward")
+		return
+	}
+
+	recordAudit(r, model.AuditActionRewardUpdate, "reward", reward.ID.Hex(), before, reward)
+
+	json.NewEncoder(w).Encode(reward)
+}
+
+// AdminDeleteReward removes a catalog item
+// Backend: DELETE /api/admin/rewards/{id} (admin role)
+func AdminDeleteReward(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.RewardServiceInstance.GetReward(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error deleting reward")
+		return
+	}
+
+	if err := service.RewardServiceInstance.DeleteReward(ctx, before.ID.Hex()); err != nil {
+		writeServiceError(w, r, err, "Error deleting reward")
+		return
+	}
+
+	recordAudit(r, model.AuditActionRewardDelete, "reward", before.ID.Hex(), before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Reward deleted successfully"})
+}
+
+// AdminListRedemptions returns all redemptions for fulfilment
+// Backend: GET /api/admin/redemptions (admin role)
+// Query params: status (optional: pending, fulfilled, cancelled, refunded)
+func AdminListRedemptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	redemptions, err := service.RewardServiceInstance.ListRedemptions(ctx, r.URL.Query().Get("status"))
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching redemptions")
+		return
+	}
+
+	if redemptions == nil {
+		redemptions = []model.Redemption{}
+	}
+
+	json.NewEncoder(w).Encode(redemptions)
+}
+
+// AdminUpdateRedemptionStatus moves a redemption through its lifecycle
+// Backend: PUT /api/admin/redemptions/{id}/status (admin role)
+// Request body: { status: "fulfilled" | "cancelled" | "refunded" }
+// Cancelling or refunding returns the points to the user
+func AdminUpdateRedemptionStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.RewardServiceInstance.GetRedemption(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating redemption")
+		return
+	}
+
+	redemption, err := service.RewardServiceInstance.UpdateRedemptionStatus(ctx, before.ID.Hex(), req.Status)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating redemption")
+		return
+	}
+
+	recordAudit(r, model.AuditActionRedemptionStatus, "redemption", redemption.ID.Hex(), before, redemption)
+
+	json.NewEncoder(w).Encode(redemption)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSessions lists the devices the logged-in user is signed in on
+// Frontend: GET /api/users/sessions (authenticated)
+// Response: array of { id, userAgent, ip, createdAt, lastUsedAt, expiresAt, current },
+// most recently used first; current marks the session making this request
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := service.RefreshTokenServiceInstance.ListSessions(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching sessions")
+		return
+	}
+
+	// Return empty array if no sessions (instead of null)
+	if sessions == nil {
+		sessions = []model.RefreshTokenFamily{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession signs the user out of one session
+// Frontend: DELETE /api/users/sessions/{id} (authenticated)
+// The session's refresh token stops working and its access tokens are
+// rejected from the next request on
+// Errors: 404 session_not_found
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := service.RefreshTokenServiceInstance.RevokeSession(ctx, claims.UserID, mux.Vars(r)["id"]); err != nil {
+		writeServiceError(w, r, err, "Error revoking session")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked successfully"})
+}
@@ -3,8 +3,11 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"rewardpage/middleware"
+	"rewardpage/model"
 	"rewardpage/service"
 	"rewardpage/utils"
 	"time"
@@ -26,7 +29,7 @@ func GetStreak(w http.ResponseWriter, r *http.Request) {
 
 	streak, err := service.StreakServiceInstance.GetStreakByUserID(ctx, userID)
 	if err != nil {
-		http.Error(w, "Error fetching streak", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error fetching streak")
 		return
 	}
 
@@ -36,15 +39,20 @@ func GetStreak(w http.ResponseWriter, r *http.Request) {
 // UpdateStreak performs a daily check-in and updates the streak
 // Frontend: POST /api/streak/update (authenticated)
 // Request body: {} (empty - today's day is determined server-side)
-// Response: { id, userId, mon, tue, wed, thu, fri, sat, sun, lastCheckIn, updatedAt }
+// Response: streak object ({ mon..sun, lastCheckIn, currentStreak, longestStreak, ... })
+// plus { alreadyCheckedIn, pointsAwarded, freezeEarned, milestones, bonusPoints }
 // Called by DailyStreak.jsx when user clicks "Check in Today" button
-// Points: Adds 5 points to user for daily check-in
+// Points: Adds 5 points to user for the first check-in of the day, plus the
+// bonus of any streak milestone reached (e.g. 7, 30, 100 days)
 // Logic:
-// 1. Determine current day of week (Mon-Sun)
-// 2. Set that day to true in the streak
+// 1. Determine current day of week (Mon-Sun) in the user's timezone
+// 2. Set that day to true in the streak and extend the consecutive-day count
 // 3. Update lastCheckIn timestamp
-// 4. Award 5 points
-// 5. Return updated streak object for DailyStreak component to display
+// 4. Award 5 points (only once per day)
+// 5. Award milestone bonuses (only once per milestone per streak)
+// 6. Return updated streak object for DailyStreak component to display
+// Errors: 500 when the check-in points could not be credited; the check-in
+// stands and repeating the request credits them
 func UpdateStreak(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -56,20 +64,43 @@ func UpdateStreak(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Update streak with today's check-in
-	updatedStreak, err := service.StreakServiceInstance.UpdateStreak(ctx, userID)
+	result, err := service.StreakServiceInstance.UpdateStreak(ctx, userID)
 	if err != nil {
-		http.Error(w, "Error updating streak", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error updating streak")
+		return
+	}
+
+	// Add 5 points to user for daily check-in (recorded in the points ledger)
+	// Awarded once per check-in day, so a retried request never pays twice. It
+	// is attempted again for a day already checked in: that is how a retry pays
+	// the points of a check-in whose award failed.
+	_, err = service.LedgerServiceInstance.Award(ctx, userID, 5, model.PointsReasonStreakCheckIn, result.LastCheckInDay)
+	switch {
+	case err == nil:
+		result.PointsAwarded = 5
+	case errors.Is(err, service.ErrAlreadyAwarded), errors.Is(err, service.ErrEmailNotVerified):
+		// Paid before, or the account does not earn until its email is verified
+	default:
+		writeServiceError(w, r, err, "Error awarding check-in points")
 		return
 	}
 
-	// Add 5 points to user for daily check-in
-	_ = service.LeaderboardServiceInstance.AddPointsToUser(ctx, userID, 5)
+	if !result.AlreadyCheckedIn || result.PointsAwarded > 0 {
+		awards, err := service.StreakMilestoneServiceInstance.AwardMilestones(ctx, userID, result.Streak)
+		if err != nil {
+			log.Printf("streak milestones for user %s: %v", userID, err)
+		}
+		for _, award := range awards {
+			result.BonusPoints += award.BonusPoints
+		}
+		result.Milestones = awards
+	}
 
-	json.NewEncoder(w).Encode(updatedStreak)
+	json.NewEncoder(w).Encode(result)
 }
 
 // GetStreakCount returns the number of consecutive days checked in
-// Frontend: GET /api/streak/count (authenticated) - optional, for displaying total
+// Frontend: GET /api/streak/count (authenticated) - optional, for displaying current streak
 // Response: { count: 5 }
 // Could be used for achievements or special display on DailyStreak component
 func GetStreakCount(w http.ResponseWriter, r *http.Request) {
@@ -83,7 +114,7 @@ func GetStreakCount(w http.ResponseWriter, r *http.Request) {
 
 	count, err := service.StreakServiceInstance.GetStreakCount(ctx, userID)
 	if err != nil {
-		http.Error(w, "Error fetching streak count", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error fetching streak count")
 		return
 	}
 
@@ -91,3 +122,69 @@ func GetStreakCount(w http.ResponseWriter, r *http.Request) {
 		"count": count,
 	})
 }
+
+// GetStreakStats returns the user's streak statistics
+// Frontend: GET /api/streak/stats (authenticated)
+// Response: { currentStreak, longestStreak, totalCheckIns, lastCheckInDay }
+func GetStreakStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+	userID := claims.UserID
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	stats, err := service.StreakServiceInstance.GetStreakStats(ctx, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching streak stats")
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
+
+// BuyStreakFreeze spends points on a streak freeze item
+// Frontend: POST /api/streak/freezes (authenticated)
+// Request body: {} (price is service.StreakFreezeCost)
+// Response: updated streak object including the new freezes count
+// Errors: 402 insufficient points, 409 freeze inventory full
+// Freezes are used automatically to cover missed days
+func BuyStreakFreeze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	streak, err := service.StreakServiceInstance.BuyFreeze(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error buying streak freeze")
+		return
+	}
+
+	json.NewEncoder(w).Encode(streak)
+}
+
+// RepairStreak restores a streak that broke within the last 48 hours
+// Frontend: POST /api/streak/repair (authenticated)
+// Request body: {} (price is service.StreakRepairCost)
+// Response: updated streak object with the restored currentStreak
+// Errors: 402 insufficient points, 409 nothing to repair / repair window passed
+func RepairStreak(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	streak, err := service.StreakServiceInstance.RepairStreak(ctx, claims.UserID)
+	if err != nil {
+		writeServiceError(w, r, err, "Error repairing streak")
+		return
+	}
+
+	json.NewEncoder(w).Encode(streak)
+}
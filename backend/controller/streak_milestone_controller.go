@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetStreakMilestones returns the milestone bonuses users can work towards
+// Frontend: GET /api/streak/milestones (authenticated)
+// Response: array of { id, days, bonusPoints, title }, shortest streak first
+func GetStreakMilestones(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	milestones, err := service.StreakMilestoneServiceInstance.ListMilestones(ctx)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching streak milestones")
+		return
+	}
+
+	// Return empty array if no milestones (instead of null)
+	if milestones == nil {
+		milestones = []model.StreakMilestone{}
+	}
+
+	json.NewEncoder(w).Encode(milestones)
+}
+
+// AdminCreateStreakMilestone adds a milestone bonus
+// Backend: POST /api/admin/streak-milestones (admin role)
+// Request body: { days, bonusPoints, title }
+// Response: 201 created milestone
+func AdminCreateStreakMilestone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.StreakMilestoneInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	milestone, err := service.StreakMilestoneServiceInstance.CreateMilestone(ctx, input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error creating streak milestone")
+		return
+	}
+
+	recordAudit(r, model.AuditActionStreakMilestoneCreate, "streak_milestone", milestone.ID.Hex(), nil, milestone)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(milestone)
+}
+
+// AdminUpdateStreakMilestone replaces a milestone's fields
+// Backend: PUT /api/admin/streak-milestones/{id} (admin role)
+// Request body: { days, bonusPoints, title }
+func AdminUpdateStreakMilestone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.StreakMilestoneInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.StreakMilestoneServiceInstance.GetMilestone(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating streak milestone")
+		return
+	}
+
+	milestone, err := service.StreakMilestoneServiceInstance.UpdateMilestone(ctx, before.ID.Hex(), input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating streak milestone")
+		return
+	}
+
+	recordAudit(r, model.AuditActionStreakMilestoneUpdate, "streak_milestone", milestone.ID.Hex(), before, milestone)
+
+	json.NewEncoder(w).Encode(milestone)
+}
+
+// AdminDeleteStreakMilestone removes a milestone bonus
+// Backend: DELETE /api/admin/streak-milestones/{id} (admin role)
+func AdminDeleteStreakMilestone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.StreakMilestoneServiceInstance.GetMilestone(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error deleting streak milestone")
+		return
+	}
+
+	if err := service.StreakMilestoneServiceInstance.DeleteMilestone(ctx, before.ID.Hex()); err != nil {
+		writeServiceError(w, r, err, "Error deleting streak milestone")
+		return
+	}
+
+	recordAudit(r, model.AuditActionStreakMilestoneDelete, "streak_milestone", before.ID.Hex(), before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Streak milestone deleted successfully"})
+}
@@ -27,7 +27,7 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
 
 	tasks, err := service.TaskServiceInstance.GetTasksByUserID(ctx, userID)
 	if err != nil {
-		http.Error(w, "Error fetching tasks", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error fetching tasks")
 		return
 	}
 
@@ -59,12 +59,12 @@ func CompleteTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.TaskID == "" {
-		http.Error(w, "taskId is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "TaskId is required")
 		return
 	}
 
@@ -74,12 +74,12 @@ func CompleteTask(w http.ResponseWriter, r *http.Request) {
 	// Mark task as completed
 	err := service.TaskServiceInstance.CompleteTask(ctx, userID, req.TaskID)
 	if err != nil {
-		http.Error(w, "Error completing task", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error completing task")
 		return
 	}
 
-	// Add 10 points to user for completing task
-	_ = service.LeaderboardServiceInstance.AddPointsToUser(ctx, userID, 10)
+	// Add 10 points to user for completing task (recorded in the points ledger)
+	_, _ = service.LedgerServiceInstance.Award(ctx, userID, 10, model.PointsReasonLegacyTask, req.TaskID)
 
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Task completed successfully",
@@ -99,12 +99,12 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	var task model.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
 		return
 	}
 
 	if task.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Title is required")
 		return
 	}
 
@@ -117,7 +117,7 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	err := service.TaskServiceInstance.CreateTask(ctx, &task)
 	if err != nil {
-		http.Error(w, "Error creating task", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Error creating task")
 		return
 	}
 
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminListTaskTemplates returns every daily task template
+// Backend: GET /api/admin/task-templates (admin role)
+// Response: array of { id, title, description, points, box, activeFrom, activeUntil, sortOrder }
+func AdminListTaskTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	templates, err := service.TaskTemplateServiceInstance.ListTemplates(ctx)
+	if err != nil {
+		writeServiceError(w, r, err, "Error fetching task templates")
+		return
+	}
+
+	// Return empty array if no templates (instead of null)
+	if templates == nil {
+		templates = []model.TaskTemplate{}
+	}
+
+	json.NewEncoder(w).Encode(templates)
+}
+
+// AdminCreateTaskTemplate adds a daily task template
+// Backend: POST /api/admin/task-templates (admin role)
+// Request body: { title, description, points, box, activeFrom?, activeUntil?, sortOrder }
+// Response: 201 created template
+// New templates show up in users' checklists from their next daily reset
+func AdminCreateTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.TaskTemplateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	template, err := service.TaskTemplateServiceInstance.CreateTemplate(ctx, input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error creating task template")
+		return
+	}
+
+	recordAudit(r, model.AuditActionTaskTemplateCreate, "task_template", template.ID.Hex(), nil, template)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// AdminUpdateTaskTemplate replaces a template's editable fields
+// Backend: PUT /api/admin/task-templates/{id} (admin role)
+// Request body: { title, description, points, box, activeFrom?, activeUntil?, sortOrder }
+func AdminUpdateTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input model.TaskTemplateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.TaskTemplateServiceInstance.GetTemplate(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating task template")
+		return
+	}
+
+	template, err := service.TaskTemplateServiceInstance.UpdateTemplate(ctx, before.ID.Hex(), input)
+	if err != nil {
+		writeServiceError(w, r, err, "Error updating task template")
+		return
+	}
+
+	recordAudit(r, model.AuditActionTaskTemplateUpdate, "task_template", template.ID.Hex(), before, template)
+
+	json.NewEncoder(w).Encode(template)
+}
+
+// AdminDeleteTaskTemplate removes a daily task template
+// Backend: DELETE /api/admin/task-templates/{id} (admin role)
+func AdminDeleteTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	before, err := service.TaskTemplateServiceInstance.GetTemplate(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Error deleting task template")
+		return
+	}
+
+	if err := service.TaskTemplateServiceInstance.DeleteTemplate(ctx, before.ID.Hex()); err != nil {
+		writeServiceError(w, r, err, "Error deleting task template")
+		return
+	}
+
+	recordAudit(r, model.AuditActionTaskTemplateDelete, "task_template", before.ID.Hex(), before, nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Task template deleted successfully"})
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"rewardpage/middleware"
+	"rewardpage/model"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+)
+
+// VerifyEmail confirms the user's email address with the token from the emailed link
+// Frontend: GET /api/users/verify?token=... (called by the /verify-email page)
+// Response: { message, emailVerified: true }
+// Errors: 400 invalid_verification_token
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Please provide the verification token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := service.EmailVerificationServiceInstance.Verify(ctx, token); err != nil {
+		writeServiceError(w, r, err, "Error verifying email")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Email verified successfully",
+		"emailVerified": true,
+	})
+}
+
+// ResendVerification emails a new verification link to the logged-in user
+// Frontend: POST /api/users/verify/resend (authenticated)
+// Response: 202 { message }
+// Errors: 409 email_already_verified, 429 verification_resend_too_soon
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	claims := r.Context().Value(middleware.UserContextKey).(*utils.Claims)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var user model.User
+	if err := service.UserServiceInstance.FindUserByID(ctx, claims.UserID, &user); err != nil {
+		writeServiceError(w, r, err, "Error fetching user")
+		return
+	}
+
+	if err := service.EmailVerificationServiceInstance.SendVerification(ctx, &user); err != nil {
+		writeServiceError(w, r, err, "Error sending verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"rewardpage/middleware"
 	"rewardpage/router"
 	"rewardpage/service"
 
@@ -26,8 +29,22 @@ func main() {
 	}
 
 	// CHANGE: Initialize daily task service with MongoDB connection
-	// This sets up the daily task checklist system and TTL indexes
-	service.InitDailyTaskService(service.GetDB())
+	// This sets up the daily task checklist system and its indexes
+	if err := service.InitDailyTaskService(service.GetDB()); err != nil {
+		log.Panic("Failed to initialize daily tasks:", err)
+	}
+
+	// Rate limits are per instance unless RATE_LIMIT_STORE=mongo shares them
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+	case "mongo":
+		middleware.SetRateLimitStore(service.RateLimitServiceInstance)
+	default:
+		log.Panic("RATE_LIMIT_STORE must be memory or mongo, got ", store)
+	}
+
+	// Rotate the JWT signing keys in the background
+	go service.SigningKeyServiceInstance.RunRotation(context.Background())
 
 	// Application entry point
 	fmt.Println("MongoDB Api")
@@ -35,8 +52,9 @@ func main() {
 	// Add CORS middleware
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173"}, // React dev server
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"},
+		ExposedHeaders:   []string{"Idempotent-Replayed", "X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: true,
 	})
 
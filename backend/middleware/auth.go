@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"rewardpage/service"
 	"rewardpage/utils"
@@ -18,13 +19,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Authorization header missing", nil)
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid Authorization header format", nil)
 			return
 		}
 
@@ -32,24 +33,39 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Added to properly validate the token and extract claims
 		claims, err := utils.ValidateToken(parts[1])
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			utils.WriteError(w, r, http.StatusUnauthorized, "invalid_token", "Invalid token", nil)
 			return
 		}
 
-		// Check if token is blacklisted
-		// Added for logout functionality
-		ctx := context.Background()
-		isBlacklisted, err := service.BlacklistServiceInstance.IsTokenBlacklisted(ctx, parts[1])
-		if err != nil {
-			http.Error(w, "Error checking token", http.StatusInternalServerError)
-			return
+		// Check if token is blacklisted (logout); answered from an in-process
+		// cache in most cases. Tokens without a jti predate the blacklist by jti.
+		if claims.ID != "" {
+			isBlacklisted, err := service.BlacklistServiceInstance.IsTokenBlacklisted(r.Context(), claims.ID)
+			if err != nil {
+				utils.WriteError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Error checking token", nil)
+				return
+			}
+			if isBlacklisted {
+				utils.WriteError(w, r, http.StatusUnauthorized, "token_revoked", "Token is blacklisted", nil)
+				return
+			}
 		}
-		if isBlacklisted {
-			http.Error(w, "Token is blacklisted", http.StatusUnauthorized)
-			return
+
+		// Reject access tokens whose session (login) was revoked, e.g. from
+		// another device through DELETE /api/users/sessions/{id}
+		if claims.SessionID != "" {
+			err := service.RefreshTokenServiceInstance.CheckSession(r.Context(), claims.UserID, claims.SessionID)
+			if errors.Is(err, service.ErrSessionRevoked) {
+				utils.WriteError(w, r, http.StatusUnauthorized, "session_revoked", "Session has been revoked", nil)
+				return
+			}
+			if err != nil {
+				utils.WriteError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Error checking session", nil)
+				return
+			}
 		}
 
-		ctx = context.WithValue(r.Context(), UserContextKey, claims)
+		ctx := context.WithValue(r.Context(), UserContextKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -60,10 +76,31 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			claims := r.Context().Value(UserContextKey).(*utils.Claims)
 			if claims.Role != requiredRole {
-				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				utils.WriteError(w, r, http.StatusForbidden, utils.CodeForbidden, "Insufficient permissions", nil)
 				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// RequireVerifiedEmail rejects users who have not verified their email
+// Wraps the point-earning routes so unverified accounts cannot farm points
+// Must run after AuthMiddleware
+func RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value(UserContextKey).(*utils.Claims)
+
+		verified, err := service.UserServiceInstance.IsEmailVerified(r.Context(), claims.UserID)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Error checking email verification", nil)
+			return
+		}
+		if !verified {
+			utils.WriteError(w, r, http.StatusForbidden, "email_not_verified", service.ErrEmailNotVerified.Error(), nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"rewardpage/service"
+	"rewardpage/utils"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry point-awarding POSTs safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the header value (UUIDs are 36 characters)
+const maxIdempotencyKeyLength = 255
+
+// Idempotent replays the original response when a request is retried with the
+// same Idempotency-Key header
+// Must run after AuthMiddleware, since keys are scoped per user. Requests
+// without the header are passed through unchanged.
+// - first request: runs the handler and stores its status and body (24h)
+// - retry after completion: returns the stored response with Idempotent-Replayed: true
+// - retry while the first request is still running: 409
+// - same key reused for a different endpoint or body: 422
+// Server errors (5xx) are not stored, so the client can retry them.
+func Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Idempotency-Key is too long", nil)
+			return
+		}
+
+		claims, ok := r.Context().Value(UserContextKey).(*utils.Claims)
+		if !ok {
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Invalid request body", nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		lockID, existing, err := service.IdempotencyServiceInstance.Begin(ctx, claims.UserID, key, r.Method, r.URL.Path, requestHash)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Error checking Idempotency-Key", nil)
+			return
+		}
+		if existing != nil {
+			if existing.Method != r.Method || existing.Path != r.URL.Path || existing.RequestHash != requestHash {
+				utils.WriteError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request", nil)
+				return
+			}
+			if !existing.Completed {
+				utils.WriteError(w, r, http.StatusConflict, "idempotency_key_in_progress", "A request with this Idempotency-Key is still being processed", nil)
+				return
+			}
+			if existing.ContentType != "" {
+				w.Header().Set("Content-Type", existing.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(existing.StatusCode)
+			w.Write(existing.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		// The request context may be done by now; finish bookkeeping on its own
+		saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer saveCancel()
+
+		status := rec.statusCode()
+		if status >= http.StatusInternalServerError {
+			err = service.IdempotencyServiceInstance.Release(saveCtx, claims.UserID, key, lockID)
+		} else {
+			err = service.IdempotencyServiceInstance.Complete(saveCtx, claims.UserID, key, lockID, status, w.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("idempotency key for user %s: %v", claims.UserID, err)
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// statusCode returns the status written, defaulting to 200 like net/http
+func (rr *responseRecorder) statusCode() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+	return rr.status
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"rewardpage/utils"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy is the request budget of one group of routes
+// Declared per route in router.Router and applied with RateLimit
+type RateLimitPolicy struct {
+	Name   string        // Keeps the budgets of different policies apart
+	Limit  int           // Requests allowed per Window
+	Window time.Duration // Period over which Limit refills
+	ByIP   bool          // Key by client IP even when the request is authenticated
+}
+
+// RateLimitStore counts requests against a policy's budget
+// Take consumes one request for key and reports whether it was allowed, how
+// many remain and how long until the budget is restored.
+// Implementations: MemoryRateLimitStore (one instance) and
+// service.RateLimitService (shared through MongoDB)
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, reset time.Duration, err error)
+}
+
+// rateLimitStore is the store RateLimit uses, see SetRateLimitStore
+var rateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// SetRateLimitStore replaces the default in-memory store
+// Called once on startup, before the server accepts requests
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStore = store
+}
+
+// RateLimit rejects requests beyond the policy's budget with 429 rate_limited
+// Requests are keyed by the authenticated user (claims from AuthMiddleware)
+// or, on public routes and ByIP policies, by client IP. Every response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset (seconds) headers;
+// rejected ones also carry Retry-After. When the store fails the request is
+// let through, so a database hiccup does not take the API down.
+func RateLimit(policy RateLimitPolicy) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := policy.Name + ":ip:" + utils.ClientIP(r)
+			if claims, ok := r.Context().Value(UserContextKey).(*utils.Claims); ok && !policy.ByIP {
+				key = policy.Name + ":user:" + claims.UserID
+			}
+
+			allowed, remaining, reset, err := rateLimitStore.Take(r.Context(), key, policy.Limit, policy.Window)
+			if err != nil {
+				log.Printf("request %s: rate limit %s: %v", utils.RequestIDFromContext(r.Context()), policy.Name, err)
+				next(w, r)
+				return
+			}
+
+			resetSeconds := strconv.Itoa(int((reset + time.Second - 1) / time.Second))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", resetSeconds)
+			w.Header().Set("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+strconv.Itoa(int(policy.Window/time.Second)))
+
+			if !allowed {
+				w.Header().Set("Retry-After", resetSeconds)
+				utils.WriteError(w, r, http.StatusTooManyRequests, "rate_limited", "Too many requests, please slow down", nil)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+// memoryBucketSweepSize is the bucket count that triggers removing full buckets
+const memoryBucketSweepSize = 10000
+
+// MemoryRateLimitStore is a token bucket per key, held in process memory
+// A bucket holds up to limit tokens and refills at limit per window, so short
+// bursts are allowed while the long-run rate stays at the limit. Budgets are
+// not shared between API instances; use service.RateLimitService for that.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket is the state of one key
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket will be full again; swept after that
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take consumes a token from key's bucket
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	now := time.Now()
+	rate := float64(limit) / window.Seconds() // Tokens per second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= memoryBucketSweepSize {
+			s.sweep(now)
+		}
+		bucket = &tokenBucket{tokens: float64(limit), updated: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * rate
+	if bucket.tokens > float64(limit) {
+		bucket.tokens = float64(limit)
+	}
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+
+	untilFull := time.Duration((float64(limit) - bucket.tokens) / rate * float64(time.Second))
+	bucket.full = now.Add(untilFull)
+
+	if !allowed {
+		// Time until the next token
+		return false, 0, time.Duration((1 - bucket.tokens) / rate * float64(time.Second)), nil
+	}
+	return true, int(bucket.tokens), untilFull, nil
+}
+
+// sweep drops buckets that have refilled completely; they equal a new bucket
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
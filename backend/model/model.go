@@ -20,17 +20,23 @@ type Task struct {
 	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
-// CHANGE: DailyTask represents a single task in the daily checklist (1 of 5)
+// CHANGE: DailyTask represents a single task in the daily checklist
 // MongoDB collection: daily_tasks
-// Used for: 5-task daily checklist system with cooldown enforcement
+// Used for: Daily checklist system with cooldown enforcement
+// Instantiated from the active TaskTemplates when the user's day starts
 type DailyTask struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID      string             `bson:"user_id" json:"user_id"`
-	TaskNumber  int                `bson:"task_number" json:"number"` // 1-5
-	Completed   bool               `bson:"completed" json:"completed"`
-	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	ResetAt     time.Time          `bson:"reset_at" json:"reset_at"` // Midnight server time for TTL
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      string              `bson:"user_id" json:"user_id"`
+	TaskNumber  int                 `bson:"task_number" json:"number"` // 1..N in display order
+	TemplateID  *primitive.ObjectID `bson:"template_id,omitempty" json:"templateId,omitempty"`
+	Title       string              `bson:"title" json:"title"`
+	Description string              `bson:"description" json:"description"`
+	Points      int                 `bson:"points" json:"points"` // Awarded on completion
+	Box         string              `bson:"box" json:"box"`       // "left", "right", or "center" for task category
+	Completed   bool                `bson:"completed" json:"completed"`
+	CompletedAt *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	ResetAt     time.Time           `bson:"reset_at" json:"reset_at"` // Midnight server time for TTL
 }
 
 // CHANGE: DailyTaskProgress tracks user's daily progress (cooldown, completion count)
@@ -39,7 +45,7 @@ type DailyTask struct {
 type DailyTaskProgress struct {
 	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	UserID          string             `bson:"user_id" json:"user_id"`
-	CompletedCount  int                `bson:"completed_count" json:"completed_count"` // 0-5
+	CompletedCount  int                `bson:"completed_count" json:"completed_count"` // 0..number of today's tasks
 	LastCompletedAt *time.Time         `bson:"last_completed_at,omitempty" json:"last_completed_at,omitempty"`
 	LastCooldownEnd *time.Time         `bson:"last_cooldown_end,omitempty" json:"last_cooldown_end,omitempty"`
 	NextResetAt     time.Time          `bson:"next_reset_at" json:"next_reset_at"` // Next midnight
@@ -47,6 +53,33 @@ type DailyTaskProgress struct {
 	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
+// TaskTemplate defines a task that is handed to every user while it is active
+// MongoDB collection: task_templates
+// Used for: Generating each user's daily checklist (managed through admin endpoints)
+type TaskTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description" json:"description"`
+	Points      int                `bson:"points" json:"points"`
+	Box         string             `bson:"box" json:"box"`                                      // "left", "right", or "center" for task category
+	ActiveFrom  *time.Time         `bson:"active_from,omitempty" json:"activeFrom,omitempty"`   // nil = active since forever
+	ActiveUntil *time.Time         `bson:"active_until,omitempty" json:"activeUntil,omitempty"` // nil = no end date
+	SortOrder   int                `bson:"sort_order" json:"sortOrder"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// TaskTemplateInput is the admin payload for creating or updating a template
+type TaskTemplateInput struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Points      int        `json:"points"`
+	Box         string     `json:"box"`
+	ActiveFrom  *time.Time `json:"activeFrom,omitempty"`
+	ActiveUntil *time.Time `json:"activeUntil,omitempty"`
+	SortOrder   int        `json:"sortOrder"`
+}
+
 // ============ STREAK MODELS ============
 
 // Streak represents a user's weekly check-in progress
@@ -63,6 +96,85 @@ type Streak struct {
 	Sun         bool               `bson:"sun" json:"sun"`
 	LastCheckIn time.Time          `bson:"lastCheckIn" json:"lastCheckIn"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	// Consecutive-day tracking; days are "2006-01-02" in the user's timezone
+	CurrentStreak  int    `bson:"currentStreak" json:"currentStreak"`
+	LongestStreak  int    `bson:"longestStreak" json:"longestStreak"`
+	TotalCheckIns  int    `bson:"totalCheckIns" json:"totalCheckIns"`
+	LastCheckInDay string `bson:"lastCheckInDay,omitempty" json:"lastCheckInDay,omitempty"`
+	StreakStartDay string `bson:"streakStartDay,omitempty" json:"streakStartDay,omitempty"` // First day of the current run
+	WeekStart      string `bson:"weekStart,omitempty" json:"weekStart,omitempty"`           // Monday the weekday flags belong to
+	// Streak protection
+	Freezes          int    `bson:"freezes" json:"freezes"`                                       // Unused freeze items
+	FreezesUsed      int    `bson:"freezesUsed" json:"freezesUsed"`                               // Freezes consumed over all time
+	FrozenThroughDay string `bson:"frozenThroughDay,omitempty" json:"frozenThroughDay,omitempty"` // Last missed day covered by a freeze or repair
+	// Last broken run, kept so it can be repaired
+	BrokenStreak         int    `bson:"brokenStreak,omitempty" json:"brokenStreak,omitempty"`
+	BrokenStreakStartDay string `bson:"brokenStreakStartDay,omitempty" json:"brokenStreakStartDay,omitempty"`
+	BrokenAfterDay       string `bson:"brokenAfterDay,omitempty" json:"brokenAfterDay,omitempty"` // Last active day before the gap
+}
+
+// StreakCheckIn is one entry in a user's check-in log (one per user per day)
+// MongoDB collection: streak_check_ins
+// Used for: Check-in history and enforcing a single check-in per day
+type StreakCheckIn struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Day         string             `bson:"day" json:"day"`         // "2006-01-02" in the user's timezone
+	Weekday     string             `bson:"weekday" json:"weekday"` // "mon".."sun"
+	CheckedInAt time.Time          `bson:"checkedInAt" json:"checkedInAt"`
+}
+
+// StreakCheckInResult is the outcome of POST /api/streak/update
+// Embeds the streak so the response keeps the original { mon..sun, lastCheckIn } shape
+type StreakCheckInResult struct {
+	*Streak
+	AlreadyCheckedIn bool `json:"alreadyCheckedIn"`
+	PointsAwarded    int  `json:"pointsAwarded"`
+	FreezeEarned     bool `json:"freezeEarned"` // A streak freeze was added to the inventory
+	// Milestones reached by this check-in and the bonus they paid (on top of PointsAwarded)
+	Milestones  []StreakMilestoneAward `json:"milestones,omitempty"`
+	BonusPoints int                    `json:"bonusPoints"`
+}
+
+// StreakMilestone is an admin-defined bonus for reaching a streak length
+// MongoDB collection: streak_milestones
+// Used for: Extra points at e.g. 7, 30 and 100 consecutive days
+type StreakMilestone struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Days        int                `bson:"days" json:"days"` // Consecutive days required (unique)
+	BonusPoints int                `bson:"bonusPoints" json:"bonusPoints"`
+	Title       string             `bson:"title" json:"title"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// StreakMilestoneInput is the admin request body for creating/updating a milestone
+type StreakMilestoneInput struct {
+	Days        int    `json:"days"`
+	BonusPoints int    `json:"bonusPoints"`
+	Title       string `json:"title"`
+}
+
+// StreakMilestoneAward records a milestone bonus paid for one streak
+// MongoDB collection: streak_milestone_awards
+// Unique on (userId, streakStartDay, days): each milestone pays once per streak
+type StreakMilestoneAward struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID         primitive.ObjectID `bson:"userId" json:"userId"`
+	MilestoneID    primitive.ObjectID `bson:"milestoneId" json:"milestoneId"`
+	Days           int                `bson:"days" json:"days"`
+	Title          string             `bson:"title" json:"title"`
+	BonusPoints    int                `bson:"bonusPoints" json:"bonusPoints"`
+	StreakStartDay string             `bson:"streakStartDay" json:"streakStartDay"` // Identifies the streak
+	AwardedAt      time.Time          `bson:"awardedAt" json:"awardedAt"`
+}
+
+// StreakStats summarises a user's streak for GET /api/streak/stats
+type StreakStats struct {
+	CurrentStreak  int    `json:"currentStreak"`
+	LongestStreak  int    `json:"longestStreak"`
+	TotalCheckIns  int    `json:"totalCheckIns"`
+	LastCheckInDay string `json:"lastCheckInDay,omitempty"`
 }
 
 // ============ LEADERBOARD MODELS ============
@@ -77,14 +189,127 @@ type LeaderboardUser struct {
 	Rank     int                `bson:"rank" json:"rank"`
 }
 
+// ============ POINTS LEDGER MODELS ============
+
+// Reasons recorded on every points ledger entry
+// Used for: Explaining why a user's balance is what it is
+const (
+	PointsReasonDailyTask       = "daily_task"        // Daily checklist task completed
+	PointsReasonStreakCheckIn   = "streak_check_in"   // Daily streak check-in
+	PointsReasonLegacyTask      = "legacy_task"       // Legacy /api/tasks completion
+	PointsReasonAdminGrant      = "admin_grant"       // Manual adjustment by an admin
+	PointsReasonRedemption      = "reward_redemption" // Points spent on a catalog reward
+	PointsReasonRefund          = "reward_refund"     // Points returned for a cancelled/refunded redemption
+	PointsReasonReferral        = "referral"          // Referrer bonus when an invited user joins
+	PointsReasonStreakFreeze    = "streak_freeze"     // Points spent on a streak freeze item
+	PointsReasonStreakRepair    = "streak_repair"     // Points spent restoring a broken streak
+	PointsReasonStreakRefund    = "streak_refund"     // Points returned when a freeze/repair could not be applied
+	PointsReasonStreakMilestone = "streak_milestone"  // Bonus for reaching a streak milestone
+	PointsReasonOpeningBalance  = "opening_balance"   // Balance a user had before the ledger existed
+	PointsReasonReversal        = "reversal"          // Cancels an entry whose balance update failed (no transactions)
+)
+
+// PointsTransaction is a single append-only credit (positive) or debit (negative)
+// MongoDB collection: points_ledger
+// A user's balance is the sum of Amount over all of their entries
+type PointsTransaction struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"userId"`
+	Amount    int                `bson:"amount" json:"amount"`
+	Reason    string             `bson:"reason" json:"reason"`
+	SourceID  string             `bson:"source_id,omitempty" json:"sourceId,omitempty"` // Task ID, check-in day, etc.
+	AwardKey  string             `bson:"award_key,omitempty" json:"-"`                  // Set for once-only awards (unique)
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`          // Free-text reason of an admin adjustment
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// ============ REWARD MODELS ============
+
+// Redemption lifecycle statuses
+// pending -> fulfilled -> refunded, or pending -> cancelled
+const (
+	RedemptionStatusPending   = "pending"
+	RedemptionStatusFulfilled = "fulfilled"
+	RedemptionStatusCancelled = "cancelled"
+	RedemptionStatusRefunded  = "refunded"
+)
+
+// Reward is a catalog item users can redeem points for
+// MongoDB collection: rewards
+// Frontend: "Redeem Rewards" tab in rewardpage.jsx
+type Reward struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Title        string             `bson:"title" json:"title"`
+	Description  string             `bson:"description" json:"description"`
+	PointCost    int                `bson:"point_cost" json:"pointCost"`
+	Stock        int                `bson:"stock" json:"stock"`                 // Units left to redeem
+	PerUserLimit int                `bson:"per_user_limit" json:"perUserLimit"` // 0 = unlimited
+	Active       bool               `bson:"active" json:"active"`
+	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// RewardInput is the admin payload for creating or updating a catalog item
+type RewardInput struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	PointCost    int    `json:"pointCost"`
+	Stock        int    `json:"stock"`
+	PerUserLimit int    `json:"perUserLimit"`
+	Active       bool   `json:"active"`
+}
+
+// Redemption records a user spending points on a reward
+// MongoDB collection: redemptions
+// Title and cost are copied so history survives catalog edits
+type Redemption struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"userId"`
+	RewardID    primitive.ObjectID `bson:"reward_id" json:"rewardId"`
+	RewardTitle string             `bson:"reward_title" json:"rewardTitle"`
+	PointCost   int                `bson:"point_cost" json:"pointCost"`
+	Status      string             `bson:"status" json:"status"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// ============ REFERRAL MODELS ============
+
+// Referral records that one user invited another
+// MongoDB collection: referrals
+// RefereeEmail is stored normalized so aliases of one mailbox can only be referred once
+// The bonus is paid once the referee has verified their email (and the
+// referrer theirs); until then PointsAwarded is 0 and PaidAt is unset
+type Referral struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ReferrerID        primitive.ObjectID `bson:"referrer_id" json:"referrerId"`
+	RefereeID         primitive.ObjectID `bson:"referee_id" json:"refereeId"`
+	RefereeEmail      string             `bson:"referee_email" json:"-"`
+	RefereeVerifiedAt *time.Time         `bson:"referee_verified_at,omitempty" json:"refereeVerifiedAt,omitempty"`
+	PointsAwarded     int                `bson:"points_awarded" json:"pointsAwarded"`
+	PaidAt            *time.Time         `bson:"paid_at,omitempty" json:"paidAt,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at" json:"createdAt"`
+}
+
 // ============ USER MODELS (EXISTING) ============
 
-// UserInput for handling user registration/login input (includes password and role)
+// User roles checked by middleware.RequireRole
+// New accounts always start as RoleUser; only an admin can change a role
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// UserInput for handling user registration/login input (includes password)
+// There is deliberately no role field: a client must not pick its own role
 type UserInput struct {
 	Username string `json:"username" bson:"username"`
 	Email    string `json:"email" bson:"email"`
 	Password string `json:"password" bson:"password,omitempty"`
-	Role     string `json:"role,omitempty" bson:"role"` // Added for role-based authorization
+	// Optional invite code of the user who referred this registration
+	ReferralCode string `json:"referralCode,omitempty" bson:"-"`
+	// Optional IANA timezone (e.g. "Europe/Berlin") for daily resets and streak days
+	Timezone string `json:"timezone,omitempty" bson:"-"`
 }
 
 // UserOutput for responses (excludes password)
@@ -93,6 +318,24 @@ type UserOutput struct {
 	Username string             `json:"username" bson:"username"`
 	Email    string             `json:"email" bson:"email"`
 	Role     string             `json:"role" bson:"role"` // Added role to output
+	Timezone string             `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	// False until the email is verified; the frontend can offer to resend the link
+	EmailVerified bool   `json:"emailVerified" bson:"email_verified,omitempty"`
+	DisplayName   string `json:"displayName,omitempty" bson:"display_name,omitempty"`
+	AvatarURL     string `json:"avatarUrl,omitempty" bson:"avatar_url,omitempty"`
+}
+
+// ProfileUpdate is the body of PATCH /api/users/me, the only fields a user may
+// change on their own account
+// Omitted fields stay unchanged; an empty string clears displayName, avatarUrl
+// and timezone. Changing email needs currentPassword and a new verification.
+type ProfileUpdate struct {
+	Username        *string `json:"username,omitempty"`
+	DisplayName     *string `json:"displayName,omitempty"`
+	AvatarURL       *string `json:"avatarUrl,omitempty"`
+	Timezone        *string `json:"timezone,omitempty"`
+	Email           *string `json:"email,omitempty"`
+	CurrentPassword string  `json:"currentPassword,omitempty"`
 }
 
 // User for database operations (full struct)
@@ -103,11 +346,258 @@ type User struct {
 	Password string             `json:"-" bson:"password,omitempty"`
 	Role     string             `json:"role" bson:"role"`
 	Points   int                `bson:"points" json:"points"` // Points for leaderboard
+	// Referral program: own invite code and who invited this user
+	ReferralCode    string              `json:"referralCode,omitempty" bson:"referral_code,omitempty"`
+	ReferredBy      *primitive.ObjectID `json:"-" bson:"referred_by,omitempty"`
+	NormalizedEmail string              `json:"-" bson:"normalized_email,omitempty"` // Used to spot aliases of one mailbox
+	// IANA timezone used for daily resets and streak weekdays; empty = server local time
+	Timezone          string     `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	TimezoneChangedAt *time.Time `json:"-" bson:"timezone_changed_at,omitempty"` // Limits how often it can change
+	// Optional profile details, set through PATCH /api/users/me
+	DisplayName string `json:"displayName,omitempty" bson:"display_name,omitempty"`
+	AvatarURL   string `json:"avatarUrl,omitempty" bson:"avatar_url,omitempty"` // https only
+	// Accounts earn points and appear on the leaderboard only once the email is
+	// verified through GET /api/users/verify
+	EmailVerified   bool       `json:"emailVerified" bson:"email_verified,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" bson:"email_verified_at,omitempty"`
+	// Suspended accounts cannot log in or refresh tokens (set through the admin API)
+	Suspended        bool       `json:"suspended" bson:"suspended,omitempty"`
+	SuspendedAt      *time.Time `json:"suspendedAt,omitempty" bson:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspensionReason,omitempty" bson:"suspension_reason,omitempty"`
+}
+
+// UserSearch filters the admin user list
+// Query matches username or email (case-insensitive substring); empty fields match all
+type UserSearch struct {
+	Query     string
+	Role      string
+	Suspended *bool
+}
+
+// ============ AUDIT MODELS ============
+
+// Actions recorded in the audit log, "<target type>.<verb>"
+const (
+	AuditActionUserDelete            = "user.delete"
+	AuditActionUserRoleChange        = "user.role_change"
+	AuditActionUserSuspend           = "user.suspend"
+	AuditActionUserReinstate         = "user.reinstate"
+	AuditActionPointsAdjust          = "points.adjust"
+	AuditActionRewardCreate          = "reward.create"
+	AuditActionRewardUpdate          = "reward.update"
+	AuditActionRewardDelete          = "reward.delete"
+	AuditActionRedemptionStatus      = "redemption.status_change"
+	AuditActionTaskTemplateCreate    = "task_template.create"
+	AuditActionTaskTemplateUpdate    = "task_template.update"
+	AuditActionTaskTemplateDelete    = "task_template.delete"
+	AuditActionStreakMilestoneCreate = "streak_milestone.create"
+	AuditActionStreakMilestoneUpdate = "streak_milestone.update"
+	AuditActionStreakMilestoneDelete = "streak_milestone.delete"
+)
+
+// AuditEntry records one privileged action
+// MongoDB collection: audit_log (append-only)
+// Before/After hold the target as the API shows it (no password hashes);
+// Before is empty for creations and After for deletions
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ActorID    string             `bson:"actor_id" json:"actorId"`
+	ActorEmail string             `bson:"actor_email" json:"actorEmail"`
+	Action     string             `bson:"action" json:"action"`
+	TargetType string             `bson:"target_type" json:"targetType"` // "user", "reward", ...
+	TargetID   string             `bson:"target_id" json:"targetId"`
+	Before     primitive.M        `bson:"before,omitempty" json:"before,omitempty"`
+	After      primitive.M        `bson:"after,omitempty" json:"after,omitempty"`
+	RequestID  string             `bson:"request_id,omitempty" json:"requestId,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// AuditFilter narrows GET /api/admin/audit; empty fields match all
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	From     *time.Time // Inclusive
+	To       *time.Time // Exclusive
+}
+
+// Outcomes recorded for each login attempt
+const (
+	LoginResultSuccess            = "success"
+	LoginResultInvalidCredentials = "invalid_credentials"
+	LoginResultSuspended          = "suspended"        // Right password, suspended account
+	LoginResultThrottled          = "throttled"        // Rejected by backoff before the password was checked
+	LoginResultLocked             = "locked"           // Rejected by a lockout before the password was checked
+	LoginResultMFARequired        = "mfa_required"     // Right password, waiting for the second factor
+	LoginResultInvalidMFACode     = "invalid_mfa_code" // Wrong TOTP or recovery code at POST /api/users/login/mfa
+)
+
+// LoginAttempt is one call to POST /api/users/login
+// MongoDB collection: login_attempts (removed by TTL index 30 days after created_at)
+// Frontend: GET /api/admin/login-attempts lists them for admins
+type LoginAttempt struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Email     string             `bson:"email" json:"email"` // As typed, lowercased
+	UserID    string             `bson:"user_id,omitempty" json:"userId,omitempty"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent,omitempty" json:"userAgent,omitempty"`
+	Result    string             `bson:"result" json:"result"` // One of the LoginResult* constants
+	RequestID string             `bson:"request_id,omitempty" json:"requestId,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// LoginAttemptFilter narrows the admin login attempt list; empty fields match all
+type LoginAttemptFilter struct {
+	Email  string
+	IP     string
+	UserID string
+	Result string
+	From   *time.Time // Inclusive
+	To     *time.Time // Exclusive
+}
+
+// LoginThrottle is the failed-login state of one account or client IP
+// MongoDB collection: login_throttles (removed by TTL index at expires_at)
+// ID is "account:<email>" or "ip:<address>"
+type LoginThrottle struct {
+	ID            string     `bson:"_id"`
+	Failures      int        `bson:"failures"` // Since the last success, lockout or quiet period
+	LastFailureAt time.Time  `bson:"last_failure_at"`
+	NextAttemptAt *time.Time `bson:"next_attempt_at,omitempty"` // Exponential backoff
+	LockedUntil   *time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt     time.Time  `bson:"expires_at"`
+}
+
+// RefreshTokenFamily is the chain of refresh tokens issued for one login
+// MongoDB collection: refresh_token_families (removed by TTL index at expires_at)
+// Only the token whose jti equals CurrentTokenID may be used; refreshing
+// replaces it, and replaying an older token revokes the family.
+// Each family is one session: access tokens carry its ID in the sid claim
+// Frontend: GET /api/users/sessions lists them as { id, userAgent, ip, createdAt, lastUsedAt, expiresAt, current }
+type RefreshTokenFamily struct {
+	ID             string     `bson:"_id" json:"id"`
+	UserID         string     `bson:"user_id" json:"-"`
+	CurrentTokenID string     `bson:"current_token_id" json:"-"`
+	UserAgent      string     `bson:"user_agent,omitempty" json:"userAgent,omitempty"`
+	IP             string     `bson:"ip,omitempty" json:"ip,omitempty"` // Address of the last login or refresh
+	CreatedAt      time.Time  `bson:"created_at" json:"createdAt"`
+	LastUsedAt     time.Time  `bson:"last_used_at" json:"lastUsedAt"`
+	ExpiresAt      time.Time  `bson:"expires_at" json:"expiresAt"`
+	Current        bool       `bson:"-" json:"current"` // The session of the requesting access token
+	RevokedAt      *time.Time `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	RevokeReason   string     `bson:"revoke_reason,omitempty" json:"-"` // logout, logout_all, reuse_detected, suspended, password_reset, password_change
 }
 
 // BlacklistedToken for logout functionality
-// Added for token blacklisting on logout
+// MongoDB collection: blacklisted_tokens (removed by TTL index at expires_at,
+// when the token would have been rejected as expired anyway)
 type BlacklistedToken struct {
-	ID    primitive.ObjectID `json:"id,omitempty" bson:"_id"`
-	Token string             `json:"token" bson:"token"`
+	ID        string    `json:"id" bson:"_id"` // The access token's jti
+	UserID    string    `json:"userId" bson:"user_id"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expires_at"`
+}
+
+// PasswordResetToken is an emailed password reset link
+// MongoDB collection: password_reset_tokens (removed by TTL index at expires_at)
+// Only the SHA-256 of the token is stored, so a database leak cannot be used
+// to reset passwords; UsedAt makes each token single-use
+type PasswordResetToken struct {
+	ID        string     `bson:"_id"` // Hex SHA-256 of the token
+	UserID    string     `bson:"user_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+// EmailVerificationToken is an emailed link confirming a user owns an address
+// MongoDB collection: email_verification_tokens (removed by TTL index at expires_at)
+// Only the SHA-256 of the token is stored. Email is the address the link was
+// sent to; the link does nothing once the account's email has changed.
+type EmailVerificationToken struct {
+	ID        string    `bson:"_id"` // Hex SHA-256 of the token
+	UserID    string    `bson:"user_id"`
+	Email     string    `bson:"email"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// TOTPCredential is a user's authenticator app enrollment
+// MongoDB collection: totp_credentials, one document per user (_id = user ID)
+// Enabled stays false until the first code is confirmed. The secret must be
+// readable to check codes, so it is encrypted rather than hashed; recovery
+// codes are single-use and only stored as SHA-256. LastStep is the newest time step accepted, so a code cannot be
+// replayed within its validity window.
+type TOTPCredential struct {
+	UserID        string         `bson:"_id"`
+	Secret        string         `bson:"secret"` // Base32, sealed with SIGNING_KEY_SECRET (utils.KeySealer)
+	Enabled       bool           `bson:"enabled"`
+	EnabledAt     *time.Time     `bson:"enabled_at,omitempty"`
+	LastStep      int64          `bson:"last_step,omitempty"`
+	RecoveryCodes []RecoveryCode `bson:"recovery_codes,omitempty"`
+	CreatedAt     time.Time      `bson:"created_at"`
+}
+
+// RecoveryCode is one single-use code for logging in without the authenticator app
+type RecoveryCode struct {
+	Hash   string     `bson:"hash"` // Hex SHA-256 of the code
+	UsedAt *time.Time `bson:"used_at,omitempty"`
+}
+
+// OIDCLoginState is a social login between the redirect to the identity
+// provider and its callback
+// MongoDB collection: oidc_login_states (removed by TTL index at expires_at)
+// Only the SHA-256 of the state parameter is stored; the PKCE verifier and
+// the nonce never leave the server
+type OIDCLoginState struct {
+	ID           string    `bson:"_id"` // Hex SHA-256 of the state parameter
+	Provider     string    `bson:"provider"`
+	CodeVerifier string    `bson:"code_verifier"`
+	Nonce        string    `bson:"nonce"`
+	CreatedAt    time.Time `bson:"created_at"`
+	ExpiresAt    time.Time `bson:"expires_at"`
+}
+
+// ExternalIdentity links a user to their account at an OIDC provider
+// MongoDB collection: user_identities (_id = provider + ":" + subject)
+// Email is the address the provider reported when the link was made
+type ExternalIdentity struct {
+	ID          string    `bson:"_id" json:"-"`
+	Provider    string    `bson:"provider" json:"provider"`
+	Subject     string    `bson:"subject" json:"-"`
+	UserID      string    `bson:"user_id" json:"-"`
+	Email       string    `bson:"email" json:"email"`
+	CreatedAt   time.Time `bson:"created_at" json:"createdAt"`
+	LastLoginAt time.Time `bson:"last_login_at" json:"lastLoginAt"`
+}
+
+// SigningKeyRecord is one JWT signing key pair
+// MongoDB collection: jwt_signing_keys (removed by TTL index at expires_at,
+// once no token it signed can still be valid)
+// Each rotation period has exactly one key (unique period); it is created a
+// little before the period starts so it is in the JWKS before first use
+type SigningKeyRecord struct {
+	ID          string    `bson:"_id"`          // kid
+	Algorithm   string    `bson:"alg"`          // EdDSA or RS256
+	PrivateKey  string    `bson:"private_key"`  // PKCS#8 PEM, encrypted with SIGNING_KEY_SECRET
+	Period      int64     `bson:"period"`       // Rotation period number (unix time / rotation interval)
+	ActivatesAt time.Time `bson:"activates_at"` // Used for signing from here until the next period
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// IdempotencyRecord stores the outcome of a request sent with an Idempotency-Key header
+// MongoDB collection: idempotency_keys (expires after 24 hours via TTL index)
+// Used for: Replaying the original response when a client retries a point-awarding POST
+type IdempotencyRecord struct {
+	ID          string    `bson:"_id"` // Hash of user ID + key
+	UserID      string    `bson:"user_id"`
+	Method      string    `bson:"method"`
+	Path        string    `bson:"path"`
+	RequestHash string    `bson:"request_hash"` // SHA-256 of the request body
+	LockID      string    `bson:"lock_id"`      // Request that holds the key; only it may complete or release it
+	Completed   bool      `bson:"completed"`
+	StatusCode  int       `bson:"status_code,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
 }
@@ -3,30 +3,70 @@ package router
 import (
 	"rewardpage/controller"
 	"rewardpage/middleware"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// Rate limit policies, applied per route with middleware.RateLimit
+// Public routes are limited per client IP, secured routes per user
+// (ByIP policies per client IP on secured routes too)
+var (
+	registerLimit   = middleware.RateLimitPolicy{Name: "register", Limit: 5, Window: time.Hour}
+	loginLimit      = middleware.RateLimitPolicy{Name: "login", Limit: 10, Window: time.Minute}
+	refreshLimit    = middleware.RateLimitPolicy{Name: "refresh", Limit: 30, Window: time.Minute}
+	accountLimit    = middleware.RateLimitPolicy{Name: "account", Limit: 10, Window: 15 * time.Minute}                // Password and email verification flows
+	credentialLimit = middleware.RateLimitPolicy{Name: "credential", Limit: 20, Window: 15 * time.Minute, ByIP: true} // Password and 2FA code checks, one client across all its accounts
+	earnLimit       = middleware.RateLimitPolicy{Name: "earn", Limit: 30, Window: time.Minute}                        // Routes that award points
+	spendLimit      = middleware.RateLimitPolicy{Name: "spend", Limit: 20, Window: time.Minute}                       // Routes that spend points
+)
+
 func Router() *mux.Router {
 	router := mux.NewRouter()
 
+	// Every response carries an X-Request-ID that also appears in error bodies
+	router.Use(middleware.RequestID)
+
 	// ========== PUBLIC ENDPOINTS (NO AUTH REQUIRED) ==========
-	router.HandleFunc("/api/users/register", controller.Create1user).Methods("POST")
-	router.HandleFunc("/api/users/login", controller.Login).Methods("POST")
-	router.HandleFunc("/api/users/refresh", controller.Refresh).Methods("POST")
+	// Authentication endpoints are rate limited per client IP
+	router.HandleFunc("/api/users/register", middleware.RateLimit(registerLimit)(controller.Create1user)).Methods("POST")
+	router.HandleFunc("/api/users/login", middleware.RateLimit(loginLimit)(controller.Login)).Methods("POST")
+	router.HandleFunc("/api/users/login/mfa", middleware.RateLimit(loginLimit)(controller.LoginMFA)).Methods("POST")
+	router.HandleFunc("/api/users/refresh", middleware.RateLimit(refreshLimit)(controller.Refresh)).Methods("POST")
+	router.HandleFunc("/api/users/password/forgot", middleware.RateLimit(accountLimit)(controller.ForgotPassword)).Methods("POST")
+	router.HandleFunc("/api/users/password/reset", middleware.RateLimit(accountLimit)(controller.ResetPassword)).Methods("POST")
+	router.HandleFunc("/api/users/verify", middleware.RateLimit(accountLimit)(controller.VerifyEmail)).Methods("GET")
+	router.HandleFunc("/.well-known/jwks.json", controller.GetJWKS).Methods("GET")
+
+	// Social login through OpenID Connect providers (OIDC_PROVIDERS)
+	router.HandleFunc("/api/auth/oidc/providers", controller.GetOIDCProviders).Methods("GET")
+	router.HandleFunc("/api/auth/oidc/{provider}/login", middleware.RateLimit(loginLimit)(controller.OIDCLogin)).Methods("GET")
+	router.HandleFunc("/api/auth/oidc/{provider}/callback", middleware.RateLimit(loginLimit)(controller.OIDCCallback)).Methods("GET")
 
 	// ========== SECURED ENDPOINTS (AUTH REQUIRED) ==========
+	// POSTs that move points are wrapped in middleware.Idempotent so clients can
+	// retry them with an Idempotency-Key header without paying out twice
+	// Routes that earn points are also wrapped in middleware.RequireVerifiedEmail
+	// Rate limits wrap the rest, so requests over the limit never reach them
 	secured := router.PathPrefix("/api").Subrouter()
 	secured.Use(middleware.AuthMiddleware)
 
 	// User endpoints
-	secured.HandleFunc("/users/update", controller.Update1user).Methods("PUT")
-	secured.HandleFunc("/users/profile", controller.Get1user).Methods("GET")
-	secured.HandleFunc("/users/delete", controller.Delete1user).Methods("DELETE")
-	secured.HandleFunc("/users/getall", controller.GetAlluser).Methods("GET")
-	secured.HandleFunc("/users/deleteAll", controller.DeleteAlluser).Methods("DELETE")
+	secured.HandleFunc("/users/profile", controller.Me).Methods("GET") // Older alias of GET /users/me
 	secured.HandleFunc("/users/logout", controller.Logout).Methods("POST")
+	secured.HandleFunc("/users/logout-all", controller.LogoutAll).Methods("POST")
+	secured.HandleFunc("/users/sessions", controller.GetSessions).Methods("GET")
+	secured.HandleFunc("/users/sessions/{id}", controller.RevokeSession).Methods("DELETE")
+	secured.HandleFunc("/users/password/change", middleware.RateLimit(credentialLimit)(middleware.RateLimit(accountLimit)(controller.ChangePassword))).Methods("POST")
+	secured.HandleFunc("/users/verify/resend", middleware.RateLimit(accountLimit)(controller.ResendVerification)).Methods("POST")
 	secured.HandleFunc("/users/me", controller.Me).Methods("GET")
+	secured.HandleFunc("/users/me", middleware.RateLimit(accountLimit)(controller.UpdateMe)).Methods("PATCH")
+
+	// Two-factor authentication (authenticator app) enrollment
+	secured.HandleFunc("/users/mfa", controller.GetMFAStatus).Methods("GET")
+	secured.HandleFunc("/users/mfa/totp", middleware.RateLimit(accountLimit)(controller.EnrollTOTP)).Methods("POST")
+	secured.HandleFunc("/users/mfa/totp/verify", middleware.RateLimit(credentialLimit)(middleware.RateLimit(accountLimit)(controller.ConfirmTOTP))).Methods("POST")
+	secured.HandleFunc("/users/mfa/totp/disable", middleware.RateLimit(credentialLimit)(middleware.RateLimit(accountLimit)(controller.DisableTOTP))).Methods("POST")
 
 	// Task endpoints - for legacy task management
 	// Frontend: Task creation endpoints (if used)
@@ -34,29 +74,76 @@ func Router() *mux.Router {
 	secured.HandleFunc("/tasks/create", controller.CreateTask).Methods("POST") // Create new task
 	// REMOVED: secured.HandleFunc("/tasks/complete", controller.CompleteTask).Methods("POST") // Old endpoint removed to avoid route conflict
 
-	// CHANGE: Daily task checklist endpoints - 5 tasks with 5-minute cooldown
+	// CHANGE: Daily task checklist endpoints - template-driven tasks with 5-minute cooldown
 	// Frontend: NormalTasks component calls these for daily checklist system
-	secured.HandleFunc("/tasks/daily", controller.GetDailyTasks).Methods("GET")         // Fetch 5 daily tasks (auto-creates if needed)
-	secured.HandleFunc("/tasks/complete", controller.CompleteTaskDaily).Methods("POST") // Complete task with cooldown validation
-	secured.HandleFunc("/tasks/cooldown", controller.CheckCooldown).Methods("GET")      // Check cooldown status
+	secured.HandleFunc("/tasks/daily", controller.GetDailyTasks).Methods("GET")                                                                                                  // Fetch today's tasks (auto-creates from templates if needed)
+	secured.HandleFunc("/tasks/complete", middleware.RateLimit(earnLimit)(middleware.RequireVerifiedEmail(middleware.Idempotent(controller.CompleteTaskDaily)))).Methods("POST") // Complete task with cooldown validation
+	secured.HandleFunc("/tasks/cooldown", controller.CheckCooldown).Methods("GET")                                                                                               // Check cooldown status
 
 	// Streak endpoints - for weekly check-in grid
 	// Frontend: DailyStreak component calls these
-	secured.HandleFunc("/streak", controller.GetStreak).Methods("GET")            // Fetch current streak data
-	secured.HandleFunc("/streak/update", controller.UpdateStreak).Methods("POST") // Check in for today
+	secured.HandleFunc("/streak", controller.GetStreak).Methods("GET")                                                                                                     // Fetch current streak data
+	secured.HandleFunc("/streak/update", middleware.RateLimit(earnLimit)(middleware.RequireVerifiedEmail(middleware.Idempotent(controller.UpdateStreak)))).Methods("POST") // Check in for today
+	secured.HandleFunc("/streak/count", controller.GetStreakCount).Methods("GET")                                                                                          // Current consecutive-day streak
+	secured.HandleFunc("/streak/stats", controller.GetStreakStats).Methods("GET")                                                                                          // Current, longest and total check-ins
+	secured.HandleFunc("/streak/freezes", middleware.RateLimit(spendLimit)(middleware.Idempotent(controller.BuyStreakFreeze))).Methods("POST")                             // Buy a streak freeze with points
+	secured.HandleFunc("/streak/repair", middleware.RateLimit(spendLimit)(middleware.Idempotent(controller.RepairStreak))).Methods("POST")                                 // Restore a streak broken within 48h
+	secured.HandleFunc("/streak/milestones", controller.GetStreakMilestones).Methods("GET")                                                                                // Milestone bonuses (7/30/100 days...)
 
 	// Leaderboard endpoints - for ranking display
 	// Frontend: LeaderboardBox component calls these
 	secured.HandleFunc("/leaderboard", controller.GetLeaderboard).Methods("GET") // Fetch top users by points
 	secured.HandleFunc("/leaderboard/me", controller.GetUserRank).Methods("GET") // Fetch logged-in user's rank
 
-	// Legacy endpoints (kept for backward compatibility)
-	router.HandleFunc("/users", controller.GetAlluser).Methods("GET")
-	router.HandleFunc("/users/{id}", controller.Get1user).Methods("GET")
-	router.HandleFunc("/users", controller.Create1user).Methods("POST")
-	router.HandleFunc("/users/{id}", controller.Update1user).Methods("PUT")
-	router.HandleFunc("/users/{id}", controller.Delete1user).Methods("DELETE")
-	router.HandleFunc("/users", controller.DeleteAlluser).Methods("DELETE")
+	// Points endpoints - ledger of every credit and debit
+	secured.HandleFunc("/points/history", controller.GetPointsHistory).Methods("GET") // Paginated transaction history
+
+	// Reward endpoints - catalog and redemption
+	// Frontend: "Redeem Rewards" tab in rewardpage.jsx
+	secured.HandleFunc("/rewards", controller.GetRewards).Methods("GET")                                                                         // Active catalog
+	secured.HandleFunc("/rewards/redemptions", controller.GetMyRedemptions).Methods("GET")                                                       // User's redemption history
+	secured.HandleFunc("/rewards/{id}/redeem", middleware.RateLimit(spendLimit)(middleware.Idempotent(controller.RedeemReward))).Methods("POST") // Spend points on a reward
+
+	// Referral endpoints - invite code and stats
+	// Frontend: referPoints.jsx calls this
+	secured.HandleFunc("/referrals", controller.GetReferrals).Methods("GET") // Referral code, count and points earned
+
+	// ========== ADMIN ENDPOINTS (ADMIN ROLE REQUIRED) ==========
+	admin := secured.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireRole("admin"))
+
+	// User management
+	admin.HandleFunc("/users", controller.AdminSearchUsers).Methods("GET")
+	admin.HandleFunc("/users/{id}", controller.AdminGetUser).Methods("GET")
+	admin.HandleFunc("/users/{id}", controller.Delete1user).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/role", controller.AdminUpdateUserRole).Methods("PUT")
+	admin.HandleFunc("/users/{id}/points", middleware.Idempotent(controller.AdminAdjustPoints)).Methods("POST")
+	admin.HandleFunc("/users/{id}/suspension", controller.AdminSetUserSuspension).Methods("PUT")
+
+	// Audit trail of every admin action above
+	admin.HandleFunc("/audit", controller.AdminListAudit).Methods("GET")
+	// Logins, successful or not (brute-force investigation)
+	admin.HandleFunc("/login-attempts", controller.AdminListLoginAttempts).Methods("GET")
+
+	// Reward catalog management
+	admin.HandleFunc("/rewards", controller.AdminListRewards).Methods("GET")
+	admin.HandleFunc("/rewards", controller.AdminCreateReward).Methods("POST")
+	admin.HandleFunc("/rewards/{id}", controller.AdminUpdateReward).Methods("PUT")
+	admin.HandleFunc("/rewards/{id}", controller.AdminDeleteReward).Methods("DELETE")
+	admin.HandleFunc("/redemptions", controller.AdminListRedemptions).Methods("GET")
+	admin.HandleFunc("/redemptions/{id}/status", controller.AdminUpdateRedemptionStatus).Methods("PUT")
+
+	// Daily task template management
+	admin.HandleFunc("/task-templates", controller.AdminListTaskTemplates).Methods("GET")
+	admin.HandleFunc("/task-templates", controller.AdminCreateTaskTemplate).Methods("POST")
+	admin.HandleFunc("/task-templates/{id}", controller.AdminUpdateTaskTemplate).Methods("PUT")
+	admin.HandleFunc("/task-templates/{id}", controller.AdminDeleteTaskTemplate).Methods("DELETE")
+
+	// Streak milestone bonuses
+	admin.HandleFunc("/streak-milestones", controller.GetStreakMilestones).Methods("GET")
+	admin.HandleFunc("/streak-milestones", controller.AdminCreateStreakMilestone).Methods("POST")
+	admin.HandleFunc("/streak-milestones/{id}", controller.AdminUpdateStreakMilestone).Methods("PUT")
+	admin.HandleFunc("/streak-milestones/{id}", controller.AdminDeleteStreakMilestone).Methods("DELETE")
 
 	return router
 }
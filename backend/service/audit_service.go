@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditService keeps an append-only trail of privileged actions
+// Frontend integration: Written by the admin controllers, read through
+// GET /api/admin/audit
+type AuditService struct {
+	collection *mongo.Collection // audit_log collection
+}
+
+// NewAuditService creates a new AuditService instance
+func NewAuditService(collection *mongo.Collection) *AuditService {
+	return &AuditService{collection: collection}
+}
+
+// EnsureIndexes creates the audit log indexes
+//   - created_at: listing the whole log newest first
+//   - (actor_id, created_at) and (target_id, created_at): the admin filters
+//
+// Called once on startup from InitializeDB
+func (as *AuditService) EnsureIndexes(ctx context.Context) error {
+	_, err := as.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// Record appends an entry to the audit log
+// before and after are snapshotted through their JSON form, so they are stored
+// exactly as the API shows them and fields hidden from JSON (password hashes)
+// never reach the log. Either may be nil.
+func (as *AuditService) Record(ctx context.Context, entry model.AuditEntry, before, after interface{}) error {
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		return err
+	}
+
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+
+	_, err = as.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// List returns one page of audit entries matching filter, newest first
+// Returns: entries for the page and the total number of matches
+func (as *AuditService) List(ctx context.Context, filter model.AuditFilter, page, limit int64) ([]model.AuditEntry, int64, error) {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.From != nil || filter.To != nil {
+		createdAt := bson.M{}
+		if filter.From != nil {
+			createdAt["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			createdAt["$lt"] = *filter.To
+		}
+		query["created_at"] = createdAt
+	}
+
+	total, err := as.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := as.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []model.AuditEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// auditSnapshot converts a value to a document via its JSON encoding
+func auditSnapshot(value interface{}) (primitive.M, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot primitive.M
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"rewardpage/model"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// blacklistNegativeCacheTTL is how long a "not blacklisted" answer is reused
+// Tokens blacklisted by this process are cached at once; one blacklisted by
+// another API instance is seen here at most this much later
+const blacklistNegativeCacheTTL = 30 * time.Second
+
+// blacklistCacheSweepSize is the cache size that triggers removing stale entries
+const blacklistCacheSweepSize = 10000
+
+// BlacklistService tracks access tokens revoked before their expiry (logout)
+// Tokens are identified by jti. Entries are removed by a TTL index once the
+// token has expired, and lookups are answered from an in-process cache first,
+// so AuthMiddleware does not hit MongoDB on every request.
+type BlacklistService struct {
+	collection *mongo.Collection // blacklisted_tokens collection
+
+	mu    sync.Mutex
+	cache map[string]blacklistCacheEntry // jti -> cached answer
+}
+
+// blacklistCacheEntry is one cached lookup result
+type blacklistCacheEntry struct {
+	blacklisted bool
+	until       time.Time // Token expiry for blacklisted tokens, else a short TTL
+}
+
+// Added NewBlacklistService for initializing blacklist service
+func NewBlacklistService(collection *mongo.Collection) *BlacklistService {
+	return &BlacklistService{collection: collection, cache: make(map[string]blacklistCacheEntry)}
+}
+
+// EnsureIndexes creates the TTL index that drops entries of expired tokens
+// Entries from before tokens had a jti (no expires_at) are deleted here; those
+// tokens expired long ago.
+// Called once on startup from InitializeDB
+func (bs *BlacklistService) EnsureIndexes(ctx context.Context) error {
+	if _, err := bs.collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$exists": false}}); err != nil {
+		return err
+	}
+
+	_, err := bs.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// BlacklistToken revokes an access token until it expires
+// Blacklisting the same token twice is not an error
+func (bs *BlacklistService) BlacklistToken(ctx context.Context, tokenID, userID string, expiresAt time.Time) error {
+	_, err := bs.SpendToken(ctx, tokenID, userID, expiresAt)
+	return err
+}
+
+// SpendToken blacklists a single-use token and reports whether this call did
+// The upsert is the only write, so of concurrent calls for the same token
+// exactly one returns true (used to redeem an MFA challenge once)
+func (bs *BlacklistService) SpendToken(ctx context.Context, tokenID, userID string, expiresAt time.Time) (bool, error) {
+	result, err := bs.collection.UpdateOne(ctx,
+		bson.M{"_id": tokenID},
+		bson.M{"$setOnInsert": model.BlacklistedToken{ID: tokenID, UserID: userID, ExpiresAt: expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	bs.remember(tokenID, blacklistCacheEntry{blacklisted: true, until: expiresAt})
+	// A duplicate key means a concurrent upsert inserted it first
+	return err == nil && result.UpsertedCount == 1, nil
+}
+
+// IsTokenBlacklisted reports whether the token with this jti was revoked
+func (bs *BlacklistService) IsTokenBlacklisted(ctx context.Context, tokenID string) (bool, error) {
+	now := time.Now()
+
+	bs.mu.Lock()
+	entry, ok := bs.cache[tokenID]
+	bs.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.blacklisted, nil
+	}
+
+	var token model.BlacklistedToken
+	err := bs.collection.FindOne(ctx, bson.M{"_id": tokenID}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		bs.remember(tokenID, blacklistCacheEntry{until: now.Add(blacklistNegativeCacheTTL)})
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	bs.remember(tokenID, blacklistCacheEntry{blacklisted: true, until: token.ExpiresAt})
+	return true, nil
+}
+
+// remember stores a lookup result, sweeping stale entries when the cache is large
+func (bs *BlacklistService) remember(tokenID string, entry blacklistCacheEntry) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if len(bs.cache) >= blacklistCacheSweepSize {
+		now := time.Now()
+		for id, cached := range bs.cache {
+			if !now.Before(cached.until) {
+				delete(bs.cache, id)
+			}
+		}
+	}
+
+	bs.cache[tokenID] = entry
+}
@@ -1,35 +1,74 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"rewardpage/model"
 	"time"
 )
 
 // ShouldResetTask checks if tasks should be reset for a new day
-// Returns true if the last reset was on a different day
-func ShouldResetTask(last time.Time) bool {
-	now := time.Now()
-	return now.Format("2006-01-02") != last.Format("2006-01-02")
+// Returns true if the last reset was on a different day in the given location
+func ShouldResetTask(last time.Time, loc *time.Location) bool {
+	now := time.Now().In(loc)
+	return now.Format("2006-01-02") != last.In(loc).Format("2006-01-02")
 }
 
 // DayKey returns the current day name for streak tracking
-// Maps time.Weekday to lowercase day names used in Streak model
-func DayKey() string {
-	switch time.Now().Weekday() {
-	case time.Monday:
-		return "mon"
-	case time.Tuesday:
-		return "tue"
-	case time.Wednesday:
-		return "wed"
-	case time.Thursday:
-		return "thu"
-	case time.Friday:
-		return "fri"
-	case time.Saturday:
-		return "sat"
-	case time.Sunday:
-		return "sun"
-	default:
-		return ""
+// Maps time.Weekday (in the given location) to lowercase day names used in Streak model
+func DayKey(loc *time.Location) string {
+	return weekdayKey(time.Now().In(loc).Weekday())
+}
+
+// dayLayout is the format used for calendar-day keys ("2006-01-02")
+const dayLayout = "2006-01-02"
+
+// DayString returns the calendar day of t in the given location
+func DayString(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(dayLayout)
+}
+
+// weekStartDay returns the Monday of the week containing t (in t's location)
+// Used for: Rolling the weekly streak grid over when a new week starts
+func weekStartDay(t time.Time) string {
+	offset := (int(t.Weekday()) + 6) % 7 // Monday = 0 ... Sunday = 6
+	return t.AddDate(0, 0, -offset).Format(dayLayout)
+}
+
+// weekdayKey maps a time.Weekday to the lowercase names used in Streak model
+func weekdayKey(day time.Weekday) string {
+	return [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}[day]
+}
+
+// ErrInvalidTimezone is returned for names that are not IANA timezones
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// LoadTimezone validates an IANA timezone name such as "Europe/Berlin"
+// An empty name is allowed and means "server local time"
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// UserLocation returns the user's configured timezone
+// Falls back to server local time for users without one (or on lookup errors)
+// so existing accounts keep their previous reset behaviour
+func UserLocation(ctx context.Context, userID string) *time.Location {
+	var user model.User
+	if err := UserServiceInstance.FindUserByID(ctx, userID, &user); err != nil {
+		return time.Local
+	}
+
+	loc, err := LoadTimezone(user.Timezone)
+	if err != nil {
+		return time.Local
 	}
+	return loc
 }
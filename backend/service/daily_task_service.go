@@ -15,44 +15,67 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// CHANGE: DailyTaskService handles 5-task daily checklist with cooldown enforcement
+// CHANGE: DailyTaskService handles the daily task checklist with cooldown enforcement
+// Tasks are instantiated from the active task templates each day
 type DailyTaskService struct {
 	DB *mongo.Database
 }
 
 // CHANGE: Initialize daily task service with TTL index for auto-cleanup
-func InitDailyTaskService(db *mongo.Database) {
+// Also creates the unique (user_id, reset_at, task_number) index, so each task
+// of a day exists once however many requests create the day's tasks at once.
+// Duplicates left by older versions are removed beforehand by a migration.
+func InitDailyTaskService(db *mongo.Database) error {
 	DailyTaskServiceInstance = &DailyTaskService{DB: db}
 
 	// CHANGE: Create TTL index on ResetAt field for automatic cleanup of old tasks
 	// Prevents MongoDB from storing old daily tasks indefinitely
 	dailyTaskCollection := db.Collection("daily_tasks")
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "reset_at", Value: 1}},
-		Options: options.Index().SetExpireAfterSeconds(86400), // 24 hours
-	}
-	dailyTaskCollection.Indexes().CreateOne(context.Background(), indexModel)
+	_, err := dailyTaskCollection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "reset_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(86400), // 24 hours
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "reset_at", Value: 1}, {Key: "task_number", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	return err
 }
 
-// CHANGE: GetOrCreateDailyTasks retrieves or creates today's 5 tasks for user
+// CHANGE: GetOrCreateDailyTasks retrieves or creates today's tasks for user
 // Logic:
-// 1. Query tasks created today (between midnight and tomorrow midnight)
-// 2. If none exist, create 5 new tasks
-// 3. Return array of 5 task objects
+// 1. Query today's tasks (reset_at is the coming midnight)
+// 2. If none exist, create them from the active task templates
+// 3. Return array of task objects
 func (s *DailyTaskService) GetOrCreateDailyTasks(ctx context.Context, userID string) ([]model.DailyTask, error) {
-	collection := s.DB.Collection("daily_tasks")
-	today := getTodayMidnight()
+	today := getTodayMidnight(UserLocation(ctx, userID))
 	tomorrow := today.AddDate(0, 0, 1)
 
-	// CHANGE: Find tasks created today (between midnight and tomorrow midnight)
-	filter := bson.M{
-		"user_id": userID,
-		"reset_at": bson.M{
-			"$gte": today,
-			"$lt":  tomorrow,
-		},
+	tasks, err := s.findDailyTasks(ctx, userID, today, tomorrow)
+	if err != nil {
+		return nil, err
 	}
 
+	// CHANGE: If no tasks exist for today, create them from the templates
+	if len(tasks) == 0 {
+		tasks, err = s.createDailyTasks(ctx, userID, today, tomorrow)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tasks, nil
+}
+
+// findDailyTasks returns the user's tasks for the day starting at today, in display order
+func (s *DailyTaskService) findDailyTasks(ctx context.Context, userID string, today, tomorrow time.Time) ([]model.DailyTask, error) {
+	collection := s.DB.Collection("daily_tasks")
+
+	// CHANGE: Today's tasks carry the coming midnight as reset_at
+	filter := dailyTasksFilter(userID, today, tomorrow)
+
 	opts := options.Find().SetSort(bson.M{"task_number": 1})
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -64,54 +87,159 @@ func (s *DailyTaskService) GetOrCreateDailyTasks(ctx context.Context, userID str
 	if err = cursor.All(ctx, &tasks); err != nil {
 		return nil, err
 	}
+	return tasks, nil
+}
 
-	// CHANGE: If no tasks exist for today, create all 5 tasks
-	if len(tasks) == 0 {
-		tasks, err = s.createFiveTasks(ctx, userID, today, tomorrow)
-		if err != nil {
-			return nil, err
-		}
+// dailyTasksFilter matches the user's tasks for the day starting at today
+// Tasks are stored with reset_at set to the coming midnight, so the window is
+// (today, tomorrow]: yesterday's tasks (reset_at == today) are left out
+func dailyTasksFilter(userID string, today, tomorrow time.Time) bson.M {
+	return bson.M{
+		"user_id":  userID,
+		"reset_at": bson.M{"$gt": today, "$lte": tomorrow},
 	}
-
-	return tasks, nil
 }
 
-// CHANGE: createFiveTasks creates 5 new task documents in MongoDB
+// CHANGE: createDailyTasks creates today's task documents in MongoDB
+// One task per active template (title, description, points and box copied over);
+// falls back to DefaultTaskCount untitled tasks when no template is active
 // Each task has:
-// - TaskNumber: 1-5 (visual display)
+// - TaskNumber: 1..N (visual display)
 // - Completed: false (initial state)
 // - ResetAt: tomorrow midnight (TTL cleanup)
-func (s *DailyTaskService) createFiveTasks(ctx context.Context, userID string, today, tomorrow time.Time) ([]model.DailyTask, error) {
+// Returns the stored tasks instead when a concurrent request created them first
+func (s *DailyTaskService) createDailyTasks(ctx context.Context, userID string, today, tomorrow time.Time) ([]model.DailyTask, error) {
 	collection := s.DB.Collection("daily_tasks")
 	var tasks []model.DailyTask
 
-	// CHANGE: Create exactly 5 task documents
-	for i := 1; i <= 5; i++ {
-		task := model.DailyTask{
-			UserID:     userID,
-			TaskNumber: i,
-			Completed:  false,
-			CreatedAt:  time.Now(),
-			ResetAt:    tomorrow,
+	templates, err := TaskTemplateServiceInstance.ListActive(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(templates) == 0 {
+		for i := 1; i <= DefaultTaskCount; i++ {
+			tasks = append(tasks, model.DailyTask{
+				TaskNumber: i,
+				Title:      fmt.Sprintf("Task %d", i),
+				Points:     DefaultTaskPoints,
+			})
 		}
-		result, err := collection.InsertOne(ctx, task)
-		if err != nil {
-			return nil, err
+	} else {
+		for i, template := range templates {
+			templateID := template.ID
+			tasks = append(tasks, model.DailyTask{
+				TaskNumber:  i + 1,
+				TemplateID:  &templateID,
+				Title:       template.Title,
+				Description: template.Description,
+				Points:      template.Points,
+				Box:         template.Box,
+			})
 		}
-		task.ID = result.InsertedID.(primitive.ObjectID)
-		tasks = append(tasks, task)
+	}
+
+	documents := make([]interface{}, len(tasks))
+	for i := range tasks {
+		tasks[i].ID = primitive.NewObjectID()
+		tasks[i].UserID = userID
+		tasks[i].Completed = false
+		tasks[i].CreatedAt = time.Now()
+		tasks[i].ResetAt = tomorrow
+		documents[i] = tasks[i]
+	}
+
+	// Unordered, so every task number not taken yet is still inserted
+	_, err = collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent request created (some of) today's tasks first; each task
+		// number exists once, so return the stored set
+		return s.findDailyTasks(ctx, userID, today, tomorrow)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return tasks, nil
 }
 
+// removeDuplicateDailyTasks deletes the extra copies of daily tasks created by
+// concurrent requests before the unique (user_id, reset_at, task_number) index
+// Of each set of copies, a completed one is kept (its points were paid for that
+// task ID), otherwise the oldest.
+func removeDuplicateDailyTasks(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "completed", Value: -1}, {Key: "created_at", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "reset_at": "$reset_at", "task_number": "$task_number"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// dailyTaskCooldown is the minimum time between two task completions
+const dailyTaskCooldown = 5 * time.Minute
+
+// Errors returned by CompleteTask so controllers can map them to HTTP statuses
+var (
+	ErrDailyTaskNotFound         = errors.New("task not found")
+	ErrDailyTaskAlreadyCompleted = errors.New("task already completed")
+	ErrDailyTaskCooldown         = errors.New("cooldown period active")
+	ErrDailyTaskLimitReached     = errors.New("all daily tasks already completed")
+)
+
+// DailyTaskCooldownError is returned while the cooldown is running
+// errors.Is(err, ErrDailyTaskCooldown) matches it; Remaining says how long to wait
+type DailyTaskCooldownError struct {
+	Remaining time.Duration
+}
+
+func (e *DailyTaskCooldownError) Error() string {
+	return fmt.Sprintf("cooldown active, wait %d seconds", e.RemainingSeconds())
+}
+
+func (e *DailyTaskCooldownError) Unwrap() error {
+	return ErrDailyTaskCooldown
+}
+
+// RemainingSeconds rounds the remaining cooldown up to whole seconds
+func (e *DailyTaskCooldownError) RemainingSeconds() int {
+	return int((e.Remaining + time.Second - 1) / time.Second)
+}
+
 // CHANGE: CompleteTask marks a task as completed with strict backend validation
+// and awards its points
 // Validation rules:
-// 1. Check if user is within cooldown (5 minutes since last task)
-// 2. Check if user already completed 5 tasks today
-// 3. Check if tasks need daily reset (past midnight)
+// 1. Check if tasks need daily reset (past midnight)
+// 2. Check if user already completed all of today's tasks
+// 3. Check if user is within cooldown (5 minutes since last task)
 // 4. Update task.completed = true and task.completedAt = now
 // 5. Update progress tracking with new cooldown
+// 6. Award the task's points through the ledger
+// Steps 2-6 are one atomic unit (see completeTask), so concurrent requests
+// cannot both pass the cooldown check or complete the same task twice.
+// Only the caller's own tasks from today's reset window can be completed.
+// Errors: ErrDailyTaskNotFound, ErrDailyTaskAlreadyCompleted,
+// *DailyTaskCooldownError (ErrDailyTaskCooldown), ErrDailyTaskLimitReached
 func (s *DailyTaskService) CompleteTask(ctx context.Context, userID, taskID string) (map[string]interface{}, error) {
 	log.Printf("CompleteTask starting: userID=%s, taskID=%s", userID, taskID)
 
@@ -121,97 +249,186 @@ func (s *DailyTaskService) CompleteTask(ctx context.Context, userID, taskID stri
 		return nil, err
 	}
 
-	// CHANGE: Get current progress to check cooldown and completion limit
-	progress, err := s.GetOrCreateProgress(ctx, userID)
-	if err != nil {
-		log.Printf("GetOrCreateProgress failed: %v", err)
-		return nil, err
-	}
-	log.Printf("Progress retrieved: completedCount=%d, lastCompletedAt=%v", progress.CompletedCount, progress.LastCompletedAt)
-
-	// CHANGE: Check if user already completed 5 tasks today
-	if progress.CompletedCount >= 5 {
-		return nil, errors.New("all 5 daily tasks already completed")
-	}
-
-	// CHANGE: Check if user is within 5-minute cooldown period
-	// Backend prevents spam by enforcing this server-side
-	now := time.Now()
-	if progress.LastCompletedAt != nil {
-		elapsed := now.Sub(*progress.LastCompletedAt)
-		if elapsed < 5*time.Minute {
-			remainingSeconds := int(((5 * time.Minute) - elapsed).Seconds())
-			return map[string]interface{}{
-				"error":             fmt.Sprintf("cooldown active, wait %d seconds", remainingSeconds),
-				"remaining_seconds": remainingSeconds,
-			}, errors.New("cooldown period active")
-		}
-	}
-
 	// CHANGE: Convert taskID string to MongoDB ObjectID
 	objID, err := primitive.ObjectIDFromHex(taskID)
 	if err != nil {
 		log.Printf("Invalid task ID format: %s, error: %v", taskID, err)
-		return nil, errors.New("invalid task id format")
+		return nil, ErrDailyTaskNotFound
 	}
-	log.Printf("Task ID converted: %s -> %s", taskID, objID.Hex())
 
-	// CHANGE: Update task to completed with timestamp
+	var result map[string]interface{}
+	err = runInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = s.completeTask(ctx, userID, objID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Task completed: userID=%s, taskID=%s, points=%v", userID, taskID, result["points"])
+	return result, nil
+}
+
+// completeTask performs the completion as a sequence of conditional updates
+//  0. Look the task up among the user's tasks for today's reset window
+//  1. Claim a completion on the progress document, only if the user is under
+//     the daily limit and out of cooldown ($inc on a filtered update)
+//  2. Mark the task completed, only if it is not completed yet
+//  3. Award the task's points (ledger awards are once per task)
+//
+// Inside a transaction the steps commit or abort together. Without one, each
+// step is still race-free and earlier steps are undone if a later step fails;
+// an undo that fails itself is logged and returned (see compensate).
+func (s *DailyTaskService) completeTask(ctx context.Context, userID string, taskID primitive.ObjectID) (map[string]interface{}, error) {
+	loc := UserLocation(ctx, userID)
+	today := getTodayMidnight(loc)
+	nextResetAt := today.AddDate(0, 0, 1)
+
+	todayFilter := dailyTasksFilter(userID, today, nextResetAt)
+
+	// Step 0: the task must be one of the caller's tasks for today
 	taskCollection := s.DB.Collection("daily_tasks")
-	now = time.Now()
-	update := bson.M{
-		"$set": bson.M{
-			"completed":    true,
-			"completed_at": now,
-		},
+	taskFilter := bson.M{"_id": taskID}
+	for key, value := range todayFilter {
+		taskFilter[key] = value
+	}
+	var task model.DailyTask
+	if err := taskCollection.FindOne(ctx, taskFilter).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrDailyTaskNotFound
+		}
+		return nil, err
+	}
+	if task.Completed {
+		return nil, ErrDailyTaskAlreadyCompleted
 	}
 
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	var updatedTask model.DailyTask
-	err = taskCollection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, opts).Decode(&updatedTask)
+	// CHANGE: The number of tasks depends on how many templates were active
+	taskCount, err := taskCollection.CountDocuments(ctx, todayFilter)
 	if err != nil {
-		log.Printf("Failed to update task in database: %v", err)
 		return nil, err
 	}
-	log.Printf("Task updated successfully: taskID=%s, completed=%v", objID.Hex(), updatedTask.Completed)
 
-	// CHANGE: Update progress tracking with new completion count and cooldown
+	// Step 1: claim the completion slot and start the cooldown
 	progressCollection := s.DB.Collection("daily_task_progress")
-	newCompletedCount := progress.CompletedCount + 1
-	nextResetAt := getTodayMidnight().AddDate(0, 0, 1)
-
-	progressUpdate := bson.M{
+	now := time.Now()
+	claimFilter := bson.M{
+		"user_id":         userID,
+		"completed_count": bson.M{"$lt": taskCount},
+		"$or": bson.A{
+			bson.M{"last_completed_at": nil},
+			bson.M{"last_completed_at": bson.M{"$lte": now.Add(-dailyTaskCooldown)}},
+		},
+	}
+	claim := bson.M{
+		"$inc": bson.M{"completed_count": 1},
 		"$set": bson.M{
-			"completed_count":   newCompletedCount,
 			"last_completed_at": now,
-			"last_cooldown_end": now.Add(5 * time.Minute),
+			"last_cooldown_end": now.Add(dailyTaskCooldown),
 			"next_reset_at":     nextResetAt,
 			"updated_at":        now,
 		},
 	}
 
-	_, err = progressCollection.UpdateOne(ctx, bson.M{"user_id": userID}, progressUpdate)
+	var previous model.DailyTaskProgress
+	err = progressCollection.FindOneAndUpdate(ctx, claimFilter, claim,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return nil, s.rejectCompletion(ctx, userID, taskCount, now)
+	}
 	if err != nil {
 		return nil, err
 	}
+	undoClaim := func() error {
+		_, err := progressCollection.UpdateOne(ctx,
+			bson.M{"user_id": userID, "last_completed_at": now},
+			bson.M{
+				"$inc": bson.M{"completed_count": -1},
+				"$set": bson.M{
+					"last_completed_at": previous.LastCompletedAt,
+					"last_cooldown_end": previous.LastCooldownEnd,
+				},
+			},
+		)
+		return err
+	}
+
+	// Step 2: mark the task completed (a concurrent request may have won the race)
+	taskFilter["completed"] = false
+	var updatedTask model.DailyTask
+	err = taskCollection.FindOneAndUpdate(ctx,
+		taskFilter,
+		bson.M{"$set": bson.M{"completed": true, "completed_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedTask)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			err = ErrDailyTaskAlreadyCompleted
+		}
+		return nil, compensate(ctx, "daily task "+taskID.Hex()+": undo completion claim", err, undoClaim)
+	}
+
+	// CHANGE: Points come from the task's template (tasks created before
+	// templates existed carry no value and fall back to the default)
+	points := updatedTask.Points
+	if points <= 0 {
+		points = DefaultTaskPoints
+	}
+
+	// Step 3: award the points
+	if _, err := LedgerServiceInstance.Award(ctx, userID, points, model.PointsReasonDailyTask, taskID.Hex()); err != nil {
+		if errors.Is(err, ErrAlreadyAwarded) {
+			err = ErrDailyTaskAlreadyCompleted
+		}
+		err = compensate(ctx, "daily task "+taskID.Hex()+": undo task completion", err, func() error {
+			_, err := taskCollection.UpdateOne(ctx,
+				bson.M{"_id": taskID, "completed_at": now},
+				bson.M{"$set": bson.M{"completed": false}, "$unset": bson.M{"completed_at": ""}},
+			)
+			return err
+		})
+		return nil, compensate(ctx, "daily task "+taskID.Hex()+": undo completion claim", err, undoClaim)
+	}
 
 	// CHANGE: Return updated state to frontend
 	return map[string]interface{}{
 		"success":         true,
 		"task":            updatedTask,
-		"completed_count": newCompletedCount,
+		"points":          points,
+		"completed_count": previous.CompletedCount + 1,
 		"next_reset_at":   nextResetAt,
-		"cooldown_until":  now.Add(5 * time.Minute),
+		"timezone":        loc.String(),
+		"cooldown_until":  now.Add(dailyTaskCooldown),
 	}, nil
 }
 
+// rejectCompletion explains why the completion claim did not match
+// Either all of today's tasks are done or the cooldown is still running
+func (s *DailyTaskService) rejectCompletion(ctx context.Context, userID string, taskCount int64, now time.Time) error {
+	progress, err := s.GetOrCreateProgress(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if int64(progress.CompletedCount) >= taskCount {
+		return ErrDailyTaskLimitReached
+	}
+
+	var remaining time.Duration
+	if progress.LastCompletedAt != nil {
+		remaining = dailyTaskCooldown - now.Sub(*progress.LastCompletedAt)
+	}
+	return &DailyTaskCooldownError{Remaining: remaining}
+}
+
 // CHANGE: CheckAndResetDaily checks if past midnight and resets all tasks
 // This is called on every GET /api/tasks/daily request
 // Logic:
 // 1. Get current progress and check NextResetAt time
 // 2. If NextResetAt < now, we're past midnight
 // 3. Delete old tasks from today
-// 4. Create 5 new tasks for today
+// 4. Create today's tasks from the active templates
 // 5. Reset progress counters
 // Used for: Lazy-loaded daily reset on next user request after midnight
 func (s *DailyTaskService) CheckAndResetDaily(ctx context.Context, userID string) error {
@@ -221,26 +438,26 @@ func (s *DailyTaskService) CheckAndResetDaily(ctx context.Context, userID string
 	}
 
 	now := time.Now()
-	today := getTodayMidnight()
+	today := getTodayMidnight(UserLocation(ctx, userID))
 
 	// CHANGE: If next reset time is in the past, we've passed midnight, so reset all tasks
 	if progress.NextResetAt.Before(now) {
 		collection := s.DB.Collection("daily_tasks")
 
-		// CHANGE: Delete old tasks (before today's midnight)
+		// CHANGE: Delete old tasks (yesterday's reset at today's midnight)
 		_, err := collection.DeleteMany(ctx, bson.M{
 			"user_id": userID,
 			"reset_at": bson.M{
-				"$lt": today,
+				"$lte": today,
 			},
 		})
 		if err != nil {
 			return err
 		}
 
-		// CHANGE: Create new 5 tasks for today
+		// CHANGE: Create today's tasks from the active templates
 		tomorrow := today.AddDate(0, 0, 1)
-		_, err = s.createFiveTasks(ctx, userID, today, tomorrow)
+		_, err = s.createDailyTasks(ctx, userID, today, tomorrow)
 		if err != nil {
 			return err
 		}
@@ -269,12 +486,12 @@ func (s *DailyTaskService) CheckAndResetDaily(ctx context.Context, userID string
 
 // CHANGE: GetOrCreateProgress retrieves or creates progress tracking for user
 // Returns DailyTaskProgress which contains:
-// - CompletedCount: number of tasks completed today (0-5)
+// - CompletedCount: number of tasks completed today
 // - LastCompletedAt: timestamp of last completed task
 // - NextResetAt: next midnight (when tasks reset)
 func (s *DailyTaskService) GetOrCreateProgress(ctx context.Context, userID string) (*model.DailyTaskProgress, error) {
 	collection := s.DB.Collection("daily_task_progress")
-	today := getTodayMidnight()
+	today := getTodayMidnight(UserLocation(ctx, userID))
 	tomorrow := today.AddDate(0, 0, 1)
 
 	filter := bson.M{"user_id": userID}
@@ -302,9 +519,10 @@ func (s *DailyTaskService) GetOrCreateProgress(ctx context.Context, userID strin
 	return &progress, nil
 }
 
-// CHANGE: getTodayMidnight returns today's midnight in server time
+// CHANGE: getTodayMidnight returns today's midnight in the given location
+// Pass the user's timezone (UserLocation) so the day rolls over at their midnight
 // Used for: Determining daily reset boundaries and TTL cleanup
-func getTodayMidnight() time.Time {
-	now := time.Now()
-	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+func getTodayMidnight(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 }
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestGetOrCreateDailyTasksReturnsTheSameTasks(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	first, err := DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDailyTasks: %v", err)
+	}
+	if len(first) != DefaultTaskCount {
+		t.Fatalf("got %d tasks, want %d", len(first), DefaultTaskCount)
+	}
+
+	second, err := DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDailyTasks again: %v", err)
+	}
+	if len(second) != len(first) {
+		t.Fatalf("second call returned %d tasks, want %d", len(second), len(first))
+	}
+	for i := range first {
+		if second[i].ID != first[i].ID {
+			t.Fatalf("task %d was recreated: %s, then %s", i, first[i].ID.Hex(), second[i].ID.Hex())
+		}
+	}
+}
+
+func TestGetOrCreateDailyTasksConcurrentCreatesOneSet(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID); err != nil {
+				t.Errorf("GetOrCreateDailyTasks: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err := db.Collection("daily_tasks").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		t.Fatalf("count tasks: %v", err)
+	}
+	if stored != DefaultTaskCount {
+		t.Errorf("%d tasks stored, want %d", stored, DefaultTaskCount)
+	}
+}
+
+func TestRemoveDuplicateDailyTasksKeepsCompletedCopy(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	// A collection without the unique index, as older versions left it
+	collection := db.Collection("daily_tasks_legacy")
+
+	resetAt := time.Now().Truncate(time.Second)
+	completedAt := resetAt.Add(-time.Hour)
+	completed := model.DailyTask{
+		ID:          primitive.NewObjectID(),
+		UserID:      "u1",
+		TaskNumber:  1,
+		ResetAt:     resetAt,
+		Completed:   true,
+		CompletedAt: &completedAt,
+		CreatedAt:   resetAt.Add(-2 * time.Hour),
+	}
+	_, err := collection.InsertMany(ctx, []interface{}{
+		model.DailyTask{ID: primitive.NewObjectID(), UserID: "u1", TaskNumber: 1, ResetAt: resetAt, CreatedAt: resetAt.Add(-3 * time.Hour)},
+		completed,
+		model.DailyTask{ID: primitive.NewObjectID(), UserID: "u1", TaskNumber: 2, ResetAt: resetAt, CreatedAt: resetAt.Add(-3 * time.Hour)},
+		model.DailyTask{ID: primitive.NewObjectID(), UserID: "u1", TaskNumber: 2, ResetAt: resetAt, CreatedAt: resetAt.Add(-2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("insert tasks: %v", err)
+	}
+
+	if err := removeDuplicateDailyTasks(ctx, collection); err != nil {
+		t.Fatalf("removeDuplicateDailyTasks: %v", err)
+	}
+
+	var tasks []model.DailyTask
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"task_number": 1}))
+	if err != nil {
+		t.Fatalf("find tasks: %v", err)
+	}
+	if err := cursor.All(ctx, &tasks); err != nil {
+		t.Fatalf("decode tasks: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("%d tasks left, want 2", len(tasks))
+	}
+	if tasks[0].ID != completed.ID {
+		t.Errorf("task 1 kept %s, want the completed copy %s", tasks[0].ID.Hex(), completed.ID.Hex())
+	}
+}
+
+func TestCompleteTaskAwardsPointsOnce(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	tasks, err := DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDailyTasks: %v", err)
+	}
+	task := tasks[0]
+
+	result, err := DailyTaskServiceInstance.CompleteTask(ctx, userID, task.ID.Hex())
+	if err != nil {
+		t.Fatalf("CompleteTask: %v", err)
+	}
+	if result["completed_count"] != 1 {
+		t.Errorf("completed_count = %v, want 1", result["completed_count"])
+	}
+	if got := userPoints(t, db, userID); got != task.Points {
+		t.Errorf("points = %d, want %d", got, task.Points)
+	}
+
+	_, err = DailyTaskServiceInstance.CompleteTask(ctx, userID, task.ID.Hex())
+	if !errors.Is(err, ErrDailyTaskAlreadyCompleted) {
+		t.Errorf("second CompleteTask error = %v, want %v", err, ErrDailyTaskAlreadyCompleted)
+	}
+	if got := userPoints(t, db, userID); got != task.Points {
+		t.Errorf("points after retry = %d, want %d", got, task.Points)
+	}
+}
+
+// completeConcurrently calls CompleteTask for every task ID at the same time
+// Returns how many calls succeeded; any error other than the expected
+// rejections fails the test
+func completeConcurrently(t *testing.T, userID string, taskIDs []string) int {
+	t.Helper()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for _, taskID := range taskIDs {
+		wg.Add(1)
+		go func(taskID string) {
+			defer wg.Done()
+			_, err := DailyTaskServiceInstance.CompleteTask(context.Background(), userID, taskID)
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case errors.Is(err, ErrDailyTaskAlreadyCompleted), errors.Is(err, ErrDailyTaskCooldown):
+			default:
+				t.Errorf("CompleteTask(%s): %v", taskID, err)
+			}
+		}(taskID)
+	}
+	wg.Wait()
+	return succeeded
+}
+
+// prepareDailyTasks creates the user's tasks and progress before the racing starts
+func prepareDailyTasks(t *testing.T, userID string) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	tasks, err := DailyTaskServiceInstance.GetOrCreateDailyTasks(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDailyTasks: %v", err)
+	}
+	if _, err := DailyTaskServiceInstance.GetOrCreateProgress(ctx, userID); err != nil {
+		t.Fatalf("GetOrCreateProgress: %v", err)
+	}
+
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID.Hex()
+	}
+	return ids
+}
+
+// assertOneCompletion checks that exactly one completion was recorded and paid
+func assertOneCompletion(t *testing.T, userID string, succeeded int) {
+	t.Helper()
+
+	if succeeded != 1 {
+		t.Errorf("%d completions succeeded, want 1", succeeded)
+	}
+
+	db := DailyTaskServiceInstance.DB
+	ctx := context.Background()
+	completed, err := db.Collection("daily_tasks").CountDocuments(ctx, bson.M{"user_id": userID, "completed": true})
+	if err != nil {
+		t.Fatalf("count completed tasks: %v", err)
+	}
+	if completed != 1 {
+		t.Errorf("%d tasks marked completed, want 1", completed)
+	}
+
+	progress, err := DailyTaskServiceInstance.GetOrCreateProgress(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateProgress: %v", err)
+	}
+	if progress.CompletedCount != 1 {
+		t.Errorf("completed_count = %d, want 1", progress.CompletedCount)
+	}
+
+	if got := userPoints(t, db, userID); got != DefaultTaskPoints {
+		t.Errorf("points = %d, want %d", got, DefaultTaskPoints)
+	}
+}
+
+func TestCompleteTaskConcurrentSameTask(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	taskIDs := prepareDailyTasks(t, userID)
+
+	requests := make([]string, 10)
+	for i := range requests {
+		requests[i] = taskIDs[0]
+	}
+
+	assertOneCompletion(t, userID, completeConcurrently(t, userID, requests))
+}
+
+func TestCompleteTaskConcurrentDifferentTasks(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	taskIDs := prepareDailyTasks(t, userID)
+
+	// The cooldown allows only one of them through
+	assertOneCompletion(t, userID, completeConcurrently(t, userID, taskIDs))
+}
@@ -3,26 +3,67 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"rewardpage/utils"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const dbName = "userdb"
 const colName = "users"
-const blacklistColName = "blacklisted_tokens" // Added for logout functionality
-const tasksColName = "tasks"                  // Added for task management
-const streaksColName = "streaks"              // Added for daily streak tracking
-const leaderboardColName = "leaderboard"      // Reference to users collection for ranking
+const blacklistColName = "blacklisted_tokens"                      // Added for logout functionality
+const tasksColName = "tasks"                                       // Added for task management
+const streaksColName = "streaks"                                   // Added for daily streak tracking
+const streakCheckInsColName = "streak_check_ins"                   // Per-day check-in log
+const streakMilestonesColName = "streak_milestones"                // Milestone bonus definitions
+const streakMilestoneAwardsColName = "streak_milestone_awards"     // Milestone bonuses paid per streak
+const leaderboardColName = "leaderboard"                           // Reference to users collection for ranking
+const ledgerColName = "points_ledger"                              // Append-only points transaction history
+const rewardsColName = "rewards"                                   // Reward catalog
+const redemptionsColName = "redemptions"                           // Reward redemptions
+const rewardClaimsColName = "reward_claims"                        // Per-user redemption counters
+const referralsColName = "referrals"                               // Referral attribution
+const taskTemplatesColName = "task_templates"                      // Daily task definitions
+const idempotencyKeysColName = "idempotency_keys"                  // Stored responses for Idempotency-Key retries
+const auditLogColName = "audit_log"                                // Privileged (admin) actions
+const refreshTokenFamiliesColName = "refresh_token_families"       // Refresh token rotation state per login
+const signingKeysColName = "jwt_signing_keys"                      // JWT signing keys, one per rotation period
+const passwordResetTokensColName = "password_reset_tokens"         // Hashed single-use password reset tokens
+const emailVerificationTokensColName = "email_verification_tokens" // Hashed email verification tokens
+const loginAttemptsColName = "login_attempts"                      // Every login attempt, for admins
+const loginThrottlesColName = "login_throttles"                    // Failed-login backoff and lockout per account / IP
+const rateLimitsColName = "rate_limits"                            // Shared rate limit counters (RATE_LIMIT_STORE=mongo)
+const totpCredentialsColName = "totp_credentials"                  // Authenticator app secrets and recovery codes
+const oidcLoginStatesColName = "oidc_login_states"                 // Social logins waiting for the provider callback
+const userIdentitiesColName = "user_identities"                    // Links between users and OIDC provider accounts
+const migrationsColName = "migrations"                             // Data migrations that already ran
 
 var UserServiceInstance *UserService
-var BlacklistServiceInstance *BlacklistService     // Added for token blacklisting
-var TaskServiceInstance *TaskService               // Added for task operations
-var StreakServiceInstance *StreakService           // Added for streak operations
-var DailyTaskServiceInstance *DailyTaskService     // Added for daily task checklist
-var LeaderboardServiceInstance *LeaderboardService // Added for leaderboard ranking
-var mongoClient *mongo.Client                      // CHANGE: Store mongo client for GetDB() access
+var BlacklistServiceInstance *BlacklistService                 // Added for token blacklisting
+var TaskServiceInstance *TaskService                           // Added for task operations
+var StreakServiceInstance *StreakService                       // Added for streak operations
+var DailyTaskServiceInstance *DailyTaskService                 // Added for daily task checklist
+var LeaderboardServiceInstance *LeaderboardService             // Added for leaderboard ranking
+var LedgerServiceInstance *LedgerService                       // Points ledger (credits and debits)
+var RewardServiceInstance *RewardService                       // Reward catalog and redemptions
+var ReferralServiceInstance *ReferralService                   // Referral codes and attribution
+var TaskTemplateServiceInstance *TaskTemplateService           // Daily task templates
+var StreakMilestoneServiceInstance *StreakMilestoneService     // Streak milestone bonuses
+var IdempotencyServiceInstance *IdempotencyService             // Idempotency-Key response replay
+var AuditServiceInstance *AuditService                         // Audit trail of admin actions
+var RefreshTokenServiceInstance *RefreshTokenService           // Refresh token families
+var SigningKeyServiceInstance *SigningKeyService               // JWT signing key rotation
+var PasswordResetServiceInstance *PasswordResetService         // Forgot / reset password flow
+var EmailVerificationServiceInstance *EmailVerificationService // Email address verification
+var LoginAttemptServiceInstance *LoginAttemptService           // Login brute-force protection
+var RateLimitServiceInstance *RateLimitService                 // Rate limit counters shared by all instances
+var TOTPServiceInstance *TOTPService                           // Two-factor authentication
+var OIDCServiceInstance *OIDCService                           // Social login through OpenID Connect providers
+var mongoClient *mongo.Client                                  // CHANGE: Store mongo client for GetDB() access
+var transactionsSupported bool                                 // Replica set or sharded cluster (see runInTransaction)
 
 // InitializeDB initializes MongoDB connection and all service instances
 // Creates collections for users, tasks, streaks, and token blacklist
@@ -40,6 +81,14 @@ func InitializeDB() error {
 
 	fmt.Println("MongoDB connection success")
 
+	// Multi-document transactions need a replica set or mongos
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(context.TODO(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err == nil {
+		_, replicaSet := hello["setName"]
+		transactionsSupported = replicaSet || hello["msg"] == "isdbgrid"
+	}
+	fmt.Println("MongoDB transactions supported:", transactionsSupported)
+
 	// Initialize collections
 	userCollection := client.Database(dbName).Collection(colName)
 	fmt.Println("User collection instance is ready")
@@ -53,14 +102,178 @@ func InitializeDB() error {
 
 	// Initialize streaks collection for weekly check-in grid
 	streaksCollection := client.Database(dbName).Collection(streaksColName)
+	streakCheckInsCollection := client.Database(dbName).Collection(streakCheckInsColName)
+	streakMilestonesCollection := client.Database(dbName).Collection(streakMilestonesColName)
+	streakMilestoneAwardsCollection := client.Database(dbName).Collection(streakMilestoneAwardsColName)
 	fmt.Println("Streaks collection instance is ready")
 
+	// Initialize points ledger collection for transaction history
+	ledgerCollection := client.Database(dbName).Collection(ledgerColName)
+	fmt.Println("Points ledger collection instance is ready")
+
+	// Initialize reward catalog and redemption collections
+	rewardsCollection := client.Database(dbName).Collection(rewardsColName)
+	redemptionsCollection := client.Database(dbName).Collection(redemptionsColName)
+	rewardClaimsCollection := client.Database(dbName).Collection(rewardClaimsColName)
+	fmt.Println("Rewards collection instances are ready")
+
+	// Initialize referrals collection for invite attribution
+	referralsCollection := client.Database(dbName).Collection(referralsColName)
+	fmt.Println("Referrals collection instance is ready")
+
+	// Initialize task templates collection for daily task generation
+	taskTemplatesCollection := client.Database(dbName).Collection(taskTemplatesColName)
+	fmt.Println("Task templates collection instance is ready")
+
+	// Initialize idempotency keys collection for safe retries of point-awarding requests
+	idempotencyKeysCollection := client.Database(dbName).Collection(idempotencyKeysColName)
+	fmt.Println("Idempotency keys collection instance is ready")
+
+	// Initialize audit log collection for admin actions
+	auditLogCollection := client.Database(dbName).Collection(auditLogColName)
+	fmt.Println("Audit log collection instance is ready")
+
+	// Initialize refresh token families collection for token rotation
+	refreshTokenFamiliesCollection := client.Database(dbName).Collection(refreshTokenFamiliesColName)
+	fmt.Println("Refresh token families collection instance is ready")
+
+	// Initialize signing keys collection for JWT key rotation
+	signingKeysCollection := client.Database(dbName).Collection(signingKeysColName)
+	fmt.Println("JWT signing keys collection instance is ready")
+
+	// Initialize password reset tokens collection for account recovery
+	passwordResetTokensCollection := client.Database(dbName).Collection(passwordResetTokensColName)
+	fmt.Println("Password reset tokens collection instance is ready")
+
+	// Initialize email verification tokens collection
+	emailVerificationTokensCollection := client.Database(dbName).Collection(emailVerificationTokensColName)
+	fmt.Println("Email verification tokens collection instance is ready")
+
+	// Initialize login attempt collections for brute-force protection
+	loginAttemptsCollection := client.Database(dbName).Collection(loginAttemptsColName)
+	loginThrottlesCollection := client.Database(dbName).Collection(loginThrottlesColName)
+	fmt.Println("Login attempts collection instances are ready")
+
+	// Initialize rate limit collection for limits shared across instances
+	rateLimitsCollection := client.Database(dbName).Collection(rateLimitsColName)
+	fmt.Println("Rate limits collection instance is ready")
+
+	// Initialize TOTP credentials collection for two-factor authentication
+	totpCredentialsCollection := client.Database(dbName).Collection(totpCredentialsColName)
+	fmt.Println("TOTP credentials collection instance is ready")
+
+	// Initialize OIDC collections for social login
+	oidcLoginStatesCollection := client.Database(dbName).Collection(oidcLoginStatesColName)
+	userIdentitiesCollection := client.Database(dbName).Collection(userIdentitiesColName)
+	fmt.Println("OIDC collection instances are ready")
+
+	// Initialize migrations collection recording completed data migrations
+	migrationsCollection := client.Database(dbName).Collection(migrationsColName)
+	fmt.Println("Migrations collection instance is ready")
+
+	// Emails (reset and verification links) go through SMTP or, in development, the log
+	mailer, err := utils.NewMailSenderFromEnv()
+	if err != nil {
+		return err
+	}
+
+	// 2FA secrets are encrypted at rest with the same secret as the signing keys
+	totpSealer, err := utils.NewKeySealer(os.Getenv("SIGNING_KEY_SECRET"))
+	if err != nil {
+		return err
+	}
+
+	// Identity providers users can sign in with (OIDC_PROVIDERS)
+	oidcProviders, err := utils.OIDCProvidersFromEnv()
+	if err != nil {
+		return err
+	}
+
 	// Initialize service instances
 	UserServiceInstance = NewUserService(userCollection)
 	BlacklistServiceInstance = NewBlacklistService(blacklistCollection)
 	TaskServiceInstance = NewTaskService(tasksCollection)
-	StreakServiceInstance = NewStreakService(streaksCollection)
+	StreakServiceInstance = NewStreakService(streaksCollection, streakCheckInsCollection)
 	LeaderboardServiceInstance = NewLeaderboardService(userCollection) // Uses users collection for points
+	LedgerServiceInstance = NewLedgerService(ledgerCollection, userCollection)
+	RewardServiceInstance = NewRewardService(rewardsCollection, redemptionsCollection, rewardClaimsCollection)
+	ReferralServiceInstance = NewReferralService(referralsCollection, userCollection)
+	TaskTemplateServiceInstance = NewTaskTemplateService(taskTemplatesCollection)
+	StreakMilestoneServiceInstance = NewStreakMilestoneService(streakMilestonesCollection, streakMilestoneAwardsCollection)
+	IdempotencyServiceInstance = NewIdempotencyService(idempotencyKeysCollection)
+	AuditServiceInstance = NewAuditService(auditLogCollection)
+	RefreshTokenServiceInstance = NewRefreshTokenService(refreshTokenFamiliesCollection)
+	SigningKeyServiceInstance, err = NewSigningKeyService(signingKeysCollection)
+	if err != nil {
+		return err
+	}
+	PasswordResetServiceInstance = NewPasswordResetService(passwordResetTokensCollection, mailer)
+	EmailVerificationServiceInstance = NewEmailVerificationService(emailVerificationTokensCollection, mailer)
+	LoginAttemptServiceInstance = NewLoginAttemptService(loginAttemptsCollection, loginThrottlesCollection)
+	RateLimitServiceInstance = NewRateLimitService(rateLimitsCollection)
+	TOTPServiceInstance = NewTOTPService(totpCredentialsCollection, totpSealer)
+	OIDCServiceInstance = NewOIDCService(oidcLoginStatesCollection, userIdentitiesCollection, oidcProviders)
+
+	if err := BlacklistServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := LedgerServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := RewardServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := ReferralServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := StreakServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := StreakMilestoneServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := StreakMilestoneServiceInstance.SeedDefaults(context.TODO()); err != nil {
+		return err
+	}
+	if err := IdempotencyServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := AuditServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := RefreshTokenServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := SigningKeyServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := PasswordResetServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := EmailVerificationServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := LoginAttemptServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := RateLimitServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	if err := OIDCServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	// Data written before the current schema is brought up to date
+	if err := runMigrations(context.TODO(), migrationsCollection); err != nil {
+		return err
+	}
+	// normalized_email is only unique once the migrations have filled it in
+	if err := UserServiceInstance.EnsureIndexes(context.TODO()); err != nil {
+		return err
+	}
+	// Tokens cannot be issued or verified until the keys are loaded
+	if err := SigningKeyServiceInstance.Refresh(context.TODO()); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -70,3 +283,39 @@ func InitializeDB() error {
 func GetDB() *mongo.Database {
 	return mongoClient.Database(dbName)
 }
+
+// runInTransaction runs fn as a multi-document transaction when the server
+// supports them, retrying on transient errors
+// On a standalone server fn runs directly, so fn must stay safe on its own
+// (conditional updates, undoing earlier steps when a later one fails)
+func runInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !transactionsSupported {
+		return fn(ctx)
+	}
+
+	session, err := mongoClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// compensate undoes an earlier step after a later one failed with err
+// Inside a transaction nothing runs, the abort already rolls the steps back.
+// Otherwise a failed undo is logged and returned along with err (no longer
+// matching err's sentinel), as the earlier step now stays applied.
+func compensate(ctx context.Context, step string, err error, undo func() error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return err
+	}
+	if undoErr := undo(); undoErr != nil {
+		log.Printf("%s failed after %v: %v", step, err, undoErr)
+		return fmt.Errorf("%v; %s failed: %w", err, step, undoErr)
+	}
+	return err
+}
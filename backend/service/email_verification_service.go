@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EmailVerificationTTL is how long an emailed verification link works
+const EmailVerificationTTL = 24 * time.Hour
+
+// verificationResendInterval is the minimum time between two verification emails to one user
+const verificationResendInterval = time.Minute
+
+// Errors returned by EmailVerificationService so controllers can map them to HTTP statuses
+var (
+	ErrVerificationTokenInvalid  = errors.New("verification link is invalid or has expired")
+	ErrEmailAlreadyVerified      = errors.New("email is already verified")
+	ErrVerificationResendTooSoon = errors.New("a verification email was sent recently; please wait a minute")
+)
+
+// EmailVerificationService issues and redeems email verification tokens
+// A link is sent on registration and on request; following it marks the
+// address as verified, which unlocks earning points, the leaderboard and the
+// bonus of whoever referred the user. Only the SHA-256 of a token is stored.
+// Frontend integration: GET /api/users/verify, POST /api/users/verify/resend
+type EmailVerificationService struct {
+	collection *mongo.Collection // email_verification_tokens collection
+	mailer     utils.MailSender
+}
+
+// NewEmailVerificationService creates a new EmailVerificationService instance
+func NewEmailVerificationService(collection *mongo.Collection, mailer utils.MailSender) *EmailVerificationService {
+	return &EmailVerificationService{collection: collection, mailer: mailer}
+}
+
+// EnsureIndexes creates the verification token indexes
+//   - user_id: throttling and replacing a user's previous link
+//   - expires_at: TTL, expired tokens are removed
+//
+// Called once on startup from InitializeDB
+func (evs *EmailVerificationService) EnsureIndexes(ctx context.Context) error {
+	_, err := evs.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// SendVerification emails a verification link for the user's current address
+// Replaces any earlier link. The email is sent in the background.
+// Returns ErrEmailAlreadyVerified for verified users, or
+// ErrVerificationResendTooSoon within verificationResendInterval of the last link
+func (evs *EmailVerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	userID := user.ID.Hex()
+	now := time.Now()
+	// Throttled per address, so a link for a just-changed email is not held back
+	recent, err := evs.collection.CountDocuments(ctx, bson.M{
+		"user_id":    userID,
+		"email":      user.Email,
+		"created_at": bson.M{"$gt": now.Add(-verificationResendInterval)},
+	})
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return ErrVerificationResendTooSoon
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	if _, err := evs.collection.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	_, err = evs.collection.InsertOne(ctx, model.EmailVerificationToken{
+		ID:        hashSecretToken(token),
+		UserID:    userID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(EmailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+	msg := utils.MailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address by opening this link "+
+			"within %d hours:\n\n%s\n\nYou can start earning points once it is verified.\n",
+			user.Username, int(EmailVerificationTTL.Hours()), link),
+	}
+	go sendMail(evs.mailer, msg, "verification")
+
+	return nil
+}
+
+// Verify redeems a verification token and marks the address as verified
+// Pays out referral bonuses that were waiting for this verification; failures
+// there are logged and retried on the next verification of either party.
+// Returns ErrVerificationTokenInvalid for unknown or expired tokens, and for
+// tokens sent to an address the account no longer uses
+func (evs *EmailVerificationService) Verify(ctx context.Context, token string) (*model.User, error) {
+	var verification model.EmailVerificationToken
+	err := evs.collection.FindOneAndDelete(ctx, bson.M{
+		"_id":        hashSecretToken(token),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&verification)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := UserServiceInstance.MarkEmailVerified(ctx, verification.UserID, verification.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ReferralServiceInstance.SettleVerified(ctx, user.ID); err != nil {
+		log.Printf("Warning: Failed to pay referral bonuses for user %s: %v", user.ID.Hex(), err)
+	}
+
+	return user, nil
+}
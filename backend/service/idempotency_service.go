@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// idempotencyKeyTTL is how long a stored response can be replayed
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyLockTimeout is how long an unfinished request holds its key
+// After this the key is considered abandoned (e.g. the server restarted) and
+// a retry may run the request again
+const idempotencyLockTimeout = time.Minute
+
+// ErrIdempotencyKeyLost is returned by Complete and Release when the request
+// ran past idempotencyLockTimeout and a retry has taken the key over since
+var ErrIdempotencyKeyLost = errors.New("idempotency key was taken over by a retry")
+
+// IdempotencyService stores responses of requests sent with an Idempotency-Key
+// Frontend integration: Used by middleware.Idempotent on point-awarding POSTs
+type IdempotencyService struct {
+	collection *mongo.Collection // idempotency_keys collection
+}
+
+// NewIdempotencyService creates a new IdempotencyService instance
+func NewIdempotencyService(collection *mongo.Collection) *IdempotencyService {
+	return &IdempotencyService{collection: collection}
+}
+
+// EnsureIndexes creates the TTL index that expires stored responses
+// Called once on startup from InitializeDB
+func (is *IdempotencyService) EnsureIndexes(ctx context.Context) error {
+	_, err := is.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(idempotencyKeyTTL.Seconds())),
+	})
+	return err
+}
+
+// Begin claims an idempotency key for a request
+// Returns a lock ID when the caller now owns the key and should run the
+// request; it is passed to Complete or Release afterwards. Otherwise returns
+// the existing record, as the key was used before (completed or still
+// running). Keys are scoped per user.
+func (is *IdempotencyService) Begin(ctx context.Context, userID, key, method, path, requestHash string) (string, *model.IdempotencyRecord, error) {
+	record := model.IdempotencyRecord{
+		ID:          idempotencyRecordID(userID, key),
+		UserID:      userID,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		LockID:      primitive.NewObjectID().Hex(),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err := is.collection.InsertOne(ctx, record)
+	if err == nil {
+		return record.LockID, nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return "", nil, err
+	}
+
+	// Take over a key whose request never finished
+	result, err := is.collection.ReplaceOne(ctx, bson.M{
+		"_id":        record.ID,
+		"completed":  false,
+		"created_at": bson.M{"$lt": time.Now().Add(-idempotencyLockTimeout)},
+	}, record)
+	if err != nil {
+		return "", nil, err
+	}
+	if result.ModifiedCount == 1 {
+		return record.LockID, nil, nil
+	}
+
+	var existing model.IdempotencyRecord
+	if err := is.collection.FindOne(ctx, bson.M{"_id": record.ID}).Decode(&existing); err != nil {
+		return "", nil, err
+	}
+	return "", &existing, nil
+}
+
+// Complete stores the response for replay
+// Only the request holding lockID can complete the key; ErrIdempotencyKeyLost
+// otherwise, leaving the retry's record alone
+func (is *IdempotencyService) Complete(ctx context.Context, userID, key, lockID string, statusCode int, contentType string, body []byte) error {
+	result, err := is.collection.UpdateOne(ctx, is.lockFilter(userID, key, lockID), bson.M{
+		"$set": bson.M{
+			"completed":    true,
+			"status_code":  statusCode,
+			"content_type": contentType,
+			"body":         body,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// Release frees a key without storing a response, so the request can be retried
+// Used when the request failed with a server error. Only the request holding
+// lockID can release the key; ErrIdempotencyKeyLost otherwise.
+func (is *IdempotencyService) Release(ctx context.Context, userID, key, lockID string) error {
+	result, err := is.collection.DeleteOne(ctx, is.lockFilter(userID, key, lockID))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// lockFilter matches the key's record while lockID still holds it
+func (is *IdempotencyService) lockFilter(userID, key, lockID string) bson.M {
+	return bson.M{"_id": idempotencyRecordID(userID, key), "lock_id": lockID, "completed": false}
+}
+
+// idempotencyRecordID derives the document ID for a user's key
+func idempotencyRecordID(userID, key string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
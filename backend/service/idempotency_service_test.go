@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIdempotencyKeyTakenOverIsKeptFromTheSlowRequest(t *testing.T) {
+	db := newTestDB(t)
+	collection := db.Collection(idempotencyKeysColName)
+	is := NewIdempotencyService(collection)
+	ctx := context.Background()
+
+	begin := func() (string, bool) {
+		t.Helper()
+		lockID, existing, err := is.Begin(ctx, "user", "key", "POST", "/api/streak/update", "hash")
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		return lockID, existing == nil
+	}
+
+	slow, owned := begin()
+	if !owned {
+		t.Fatal("first request does not own the key")
+	}
+
+	// The first request runs past the lock timeout and a retry takes over
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": idempotencyRecordID("user", "key")},
+		bson.M{"$set": bson.M{"created_at": time.Now().Add(-2 * idempotencyLockTimeout)}})
+	if err != nil {
+		t.Fatalf("age record: %v", err)
+	}
+	retry, owned := begin()
+	if !owned || retry == slow {
+		t.Fatal("retry did not take the key over")
+	}
+
+	// The slow request fails; its release must not free the retry's key
+	if err := is.Release(ctx, "user", "key", slow); !errors.Is(err, ErrIdempotencyKeyLost) {
+		t.Errorf("Release by the slow request = %v, want %v", err, ErrIdempotencyKeyLost)
+	}
+	if _, owned := begin(); owned {
+		t.Error("a third request got the key while the retry holds it")
+	}
+	if err := is.Complete(ctx, "user", "key", slow, 200, "application/json", []byte("{}")); !errors.Is(err, ErrIdempotencyKeyLost) {
+		t.Errorf("Complete by the slow request = %v, want %v", err, ErrIdempotencyKeyLost)
+	}
+
+	if err := is.Complete(ctx, "user", "key", retry, 200, "application/json", []byte("{}")); err != nil {
+		t.Errorf("Complete by the retry: %v", err)
+	}
+}
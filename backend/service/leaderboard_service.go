@@ -23,6 +23,7 @@ func NewLeaderboardService(collection *mongo.Collection) *LeaderboardService {
 }
 
 // GetLeaderboard returns top ranked users sorted by points (descending)
+// Only users with a verified email are ranked
 // Assigns sequential rank numbers starting from 1
 // Called by frontend GET /api/leaderboard endpoint
 // Parameters:
@@ -39,7 +40,7 @@ func (ls *LeaderboardService) GetLeaderboard(ctx context.Context, limit int64) (
 	// Sort by points descending (highest first), then by username for consistency
 	opts := options.Find().SetSort(bson.M{"points": -1, "username": 1}).SetLimit(limit)
 
-	cursor, err := ls.collection.Find(ctx, bson.M{}, opts)
+	cursor, err := ls.collection.Find(ctx, bson.M{"email_verified": true}, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -72,13 +73,13 @@ func (ls *LeaderboardService) GetUserRank(ctx context.Context, userID string) (*
 	err = ls.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
 
-	// Calculate rank by counting users with more points
-	countHigher, err := ls.collection.CountDocuments(ctx, bson.M{"points": bson.M{"$gt": user.Points}})
+	// Calculate rank by counting ranked (verified) users with more points
+	countHigher, err := ls.collection.CountDocuments(ctx, bson.M{"email_verified": true, "points": bson.M{"$gt": user.Points}})
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +88,9 @@ func (ls *LeaderboardService) GetUserRank(ctx context.Context, userID string) (*
 	return &user, nil
 }
 
-// AddPointsToUser adds points to a user (called when task is completed or check-in successful)
-// Used internally by controllers when tasks are completed
+// AddPointsToUser adds points to a user without writing a ledger entry
+// Award paths should go through LedgerServiceInstance.Record instead so every
+// balance change has a recorded reason; kept for low-level maintenance use
 // Parameters:
 // - userID: user who earned points
 // - points: number of points to add
@@ -109,7 +111,7 @@ func (ls *LeaderboardService) AddPointsToUser(ctx context.Context, userID string
 	}
 
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rewardpage/model"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerService records every points credit and debit in an append-only ledger
+// and keeps users.points in step with it
+// Frontend integration: Called by points_controller for GET /api/points/history
+type LedgerService struct {
+	collection *mongo.Collection // points_ledger collection
+	users      *mongo.Collection // users collection holding the cached balance
+}
+
+// NewLedgerService creates a new LedgerService instance
+func NewLedgerService(collection, users *mongo.Collection) *LedgerService {
+	return &LedgerService{collection: collection, users: users}
+}
+
+// EnsureIndexes creates the ledger indexes
+//   - (user_id, created_at): paging through a user's history
+//   - award_key: unique, so an award can only be credited once; sparse because
+//     debits, refunds and entries written before awards were keyed have none
+//
+// Called once on startup from InitializeDB
+func (ls *LedgerService) EnsureIndexes(ctx context.Context) error {
+	_, err := ls.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "award_key", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	return err
+}
+
+// Errors returned by the ledger
+var (
+	ErrInsufficientPoints      = errors.New("insufficient points")                  // A debit would take a balance below zero
+	ErrAlreadyAwarded          = errors.New("points were already awarded for this") // Award repeated for the same source
+	ErrInvalidPointsAdjustment = errors.New("invalid points adjustment")            // Admin adjustment without amount or reason
+)
+
+// maxAdjustmentNoteLength bounds the free-text reason of an admin adjustment
+const maxAdjustmentNoteLength = 500
+
+// Record appends a ledger entry and applies it to the user's balance
+// Parameters:
+// - amount: positive for a credit, negative for a debit
+// - reason: one of the model.PointsReason* constants
+// - sourceID: what caused the entry (task ID, check-in day, ...)
+// The entry and the balance update are one transaction (see apply), so the
+// balance never moves without a reason and the ledger sum stays equal to it.
+func (ls *LedgerService) Record(ctx context.Context, userID string, amount int, reason, sourceID string) (*model.PointsTransaction, error) {
+	return ls.apply(ctx, userID, amount, reason, sourceID, "", "", nil, nil)
+}
+
+// Award credits points at most once per user, reason and sourceID
+// Used by the earning paths (check-in day, daily task, bonuses) so a retried or
+// concurrent request can never pay twice. Only users with a verified email earn.
+// Returns ErrAlreadyAwarded when the award was credited before, or
+// ErrEmailNotVerified when the user has not verified their email
+func (ls *LedgerService) Award(ctx context.Context, userID string, amount int, reason, sourceID string) (*model.PointsTransaction, error) {
+	if amount <= 0 || sourceID == "" {
+		return nil, fmt.Errorf("award needs a positive amount and a source")
+	}
+	awardKey := userID + ":" + reason + ":" + sourceID
+	return ls.apply(ctx, userID, amount, reason, sourceID, awardKey, "", bson.M{"email_verified": true}, ErrEmailNotVerified)
+}
+
+// Debit spends points only if the user can afford them
+// The balance check and decrement happen in a single conditional update, so two
+// concurrent debits can never take users.points below zero
+// Returns ErrInsufficientPoints when the balance is too low
+func (ls *LedgerService) Debit(ctx context.Context, userID string, amount int, reason, sourceID string) (*model.PointsTransaction, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("debit amount must be positive")
+	}
+	return ls.apply(ctx, userID, -amount, reason, sourceID, "", "", bson.M{"points": bson.M{"$gte": amount}}, ErrInsufficientPoints)
+}
+
+// Adjust applies a manual correction by an admin
+// Parameters:
+// - amount: non-zero; negative amounts are debits and cannot overdraw the balance
+// - note: why the adjustment was made (required, shown in the user's history)
+// - adminID: the admin making the change, stored as the entry's source
+func (ls *LedgerService) Adjust(ctx context.Context, userID string, amount int, note, adminID string) (*model.PointsTransaction, error) {
+	note = strings.TrimSpace(note)
+	if amount == 0 {
+		return nil, fmt.Errorf("%w: amount must not be zero", ErrInvalidPointsAdjustment)
+	}
+	if note == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidPointsAdjustment)
+	}
+	if len(note) > maxAdjustmentNoteLength {
+		return nil, fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidPointsAdjustment, maxAdjustmentNoteLength)
+	}
+
+	var guard bson.M
+	if amount < 0 {
+		guard = bson.M{"points": bson.M{"$gte": -amount}}
+	}
+	return ls.apply(ctx, userID, amount, model.PointsReasonAdminGrant, adminID, "", note, guard, ErrInsufficientPoints)
+}
+
+// apply writes the ledger entry, then updates users.points matching guard
+// guardErr is returned when the user exists but does not match guard
+// Both writes run in one transaction: the caller's, or a new one. Without
+// transaction support a failed balance update is cancelled by a reversal entry
+// (see reverse), since ledger entries are never deleted.
+func (ls *LedgerService) apply(ctx context.Context, userID string, amount int, reason, sourceID, awardKey, note string, guard bson.M, guardErr error) (*model.PointsTransaction, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	if mongo.SessionFromContext(ctx) != nil {
+		return ls.write(ctx, userObjID, amount, reason, sourceID, awardKey, note, guard, guardErr)
+	}
+
+	var entry *model.PointsTransaction
+	err = runInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		entry, err = ls.write(ctx, userObjID, amount, reason, sourceID, awardKey, note, guard, guardErr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// write performs apply's steps: insert the entry, then $inc the balance
+func (ls *LedgerService) write(ctx context.Context, userObjID primitive.ObjectID, amount int, reason, sourceID, awardKey, note string, guard bson.M, guardErr error) (*model.PointsTransaction, error) {
+	filter := bson.M{"_id": userObjID}
+	for key, value := range guard {
+		filter[key] = value
+	}
+
+	if mongo.SessionFromContext(ctx) == nil {
+		// Without a transaction a rejected update leaves an entry and its
+		// reversal behind, so the usual rejections are caught before writing
+		count, err := ls.users.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ls.rejection(ctx, userObjID, guard, guardErr)
+		}
+	}
+
+	entry := &model.PointsTransaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjID,
+		Amount:    amount,
+		Reason:    reason,
+		SourceID:  sourceID,
+		AwardKey:  awardKey,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := ls.collection.InsertOne(ctx, entry); err != nil {
+		if awardKey != "" && mongo.IsDuplicateKeyError(err) {
+			return nil, ErrAlreadyAwarded
+		}
+		return nil, err
+	}
+
+	result, err := ls.users.UpdateOne(ctx, filter, bson.M{
+		"$inc": bson.M{"points": amount},
+	})
+	if err == nil && result.MatchedCount == 0 {
+		err = ls.rejection(ctx, userObjID, guard, guardErr)
+	}
+	if err != nil {
+		return nil, compensate(ctx, "ledger entry "+entry.ID.Hex()+": reverse", err, func() error {
+			return ls.reverse(ctx, entry)
+		})
+	}
+
+	return entry, nil
+}
+
+// rejection explains why the balance update matched no user
+// The user may exist but fail the guard (balance too low, unverified)
+func (ls *LedgerService) rejection(ctx context.Context, userObjID primitive.ObjectID, guard bson.M, guardErr error) error {
+	if guard == nil {
+		return ErrUserNotFound
+	}
+	count, err := ls.users.CountDocuments(ctx, bson.M{"_id": userObjID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return guardErr
+}
+
+// reverse cancels an entry whose balance update failed, without a transaction
+// A reversal entry for the opposite amount brings the ledger sum back to the
+// balance. The entry's award key is released so the award can be paid on retry;
+// the amounts themselves are never changed.
+func (ls *LedgerService) reverse(ctx context.Context, entry *model.PointsTransaction) error {
+	_, err := ls.collection.InsertOne(ctx, &model.PointsTransaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    entry.UserID,
+		Amount:    -entry.Amount,
+		Reason:    model.PointsReasonReversal,
+		SourceID:  entry.ID.Hex(),
+		Note:      "Balance update failed",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if entry.AwardKey != "" {
+		_, err = ls.collection.UpdateOne(ctx, bson.M{"_id": entry.ID}, bson.M{"$unset": bson.M{"award_key": ""}})
+	}
+	return err
+}
+
+// GetHistory returns one page of a user's ledger entries, newest first
+// Used by frontend GET /api/points/history endpoint
+// Returns: entries for the page and the total number of entries
+func (ls *LedgerService) GetHistory(ctx context.Context, userID string, page, limit int64) ([]model.PointsTransaction, int64, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid user ID")
+	}
+
+	filter := bson.M{"user_id": userObjID}
+	total, err := ls.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := ls.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []model.PointsTransaction
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// GetBalance derives a user's balance by summing their ledger entries
+// Should always match users.points; used to size the opening balance entry
+func (ls *LedgerService) GetBalance(ctx context.Context, userID string) (int, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user ID")
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userObjID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "balance": bson.M{"$sum": "$amount"}}}},
+	}
+
+	cursor, err := ls.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Balance int `bson:"balance"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+
+	return result[0].Balance, nil
+}
+
+// BackfillOpeningBalances writes one opening_balance entry per user for the
+// points they held before the ledger existed
+// The entry is users.points minus what the ledger already accounts for, so the
+// ledger sum matches the balance afterwards; users.points itself is untouched.
+// Keyed per user like an award, so it is written at most once. Run from
+// runMigrations on startup.
+func (ls *LedgerService) BackfillOpeningBalances(ctx context.Context) error {
+	cursor, err := ls.users.Find(ctx,
+		bson.M{"points": bson.M{"$ne": 0}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		// Balance and ledger sum are read together, so a concurrent award
+		// cannot end up counted twice (on servers with transactions)
+		err := runInTransaction(ctx, func(ctx context.Context) error {
+			return ls.backfillOpeningBalance(ctx, user.ID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillOpeningBalance writes the opening_balance entry for one user
+func (ls *LedgerService) backfillOpeningBalance(ctx context.Context, userObjID primitive.ObjectID) error {
+	var user model.User
+	if err := ls.users.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil // Deleted meanwhile
+		}
+		return err
+	}
+
+	recorded, err := ls.GetBalance(ctx, userObjID.Hex())
+	if err != nil {
+		return err
+	}
+	opening := user.Points - recorded
+	if opening == 0 {
+		return nil
+	}
+
+	_, err = ls.collection.InsertOne(ctx, &model.PointsTransaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjID,
+		Amount:    opening,
+		Reason:    model.PointsReasonOpeningBalance,
+		AwardKey:  userObjID.Hex() + ":" + model.PointsReasonOpeningBalance,
+		Note:      "Points earned before the points history was recorded",
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
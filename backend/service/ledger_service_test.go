@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBackfillOpeningBalances(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	// 90 points from before the ledger, then a 10 point award through it
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	if _, err := db.Collection(colName).UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"points": 90}}); err != nil {
+		t.Fatalf("set points: %v", err)
+	}
+	if _, err := LedgerServiceInstance.Award(ctx, userID, 10, model.PointsReasonDailyTask, "task"); err != nil {
+		t.Fatalf("Award: %v", err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		if err := LedgerServiceInstance.BackfillOpeningBalances(ctx); err != nil {
+			t.Fatalf("BackfillOpeningBalances run %d: %v", run, err)
+		}
+	}
+
+	balance, err := LedgerServiceInstance.GetBalance(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 100 {
+		t.Errorf("ledger balance = %d, want 100", balance)
+	}
+	if got := userPoints(t, db, userID); got != 100 {
+		t.Errorf("points = %d, want 100", got)
+	}
+
+	count, err := db.Collection(ledgerColName).CountDocuments(ctx, bson.M{"user_id": objID, "reason": model.PointsReasonOpeningBalance})
+	if err != nil {
+		t.Fatalf("count opening entries: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d opening balance entries, want 1", count)
+	}
+}
+
+// assertLedgerMatchesBalance checks that the ledger sum equals users.points
+func assertLedgerMatchesBalance(t *testing.T, db *mongo.Database, userID string, want int) {
+	t.Helper()
+
+	balance, err := LedgerServiceInstance.GetBalance(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != want {
+		t.Errorf("ledger balance = %d, want %d", balance, want)
+	}
+	if got := userPoints(t, db, userID); got != want {
+		t.Errorf("points = %d, want %d", got, want)
+	}
+}
+
+func TestDebitWithoutEnoughPointsWritesNothing(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	_, err := LedgerServiceInstance.Debit(ctx, userID, 10, model.PointsReasonRedemption, "reward")
+	if !errors.Is(err, ErrInsufficientPoints) {
+		t.Fatalf("Debit error = %v, want %v", err, ErrInsufficientPoints)
+	}
+
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	count, err := db.Collection(ledgerColName).CountDocuments(ctx, bson.M{"user_id": objID})
+	if err != nil {
+		t.Fatalf("count entries: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d ledger entries, want 0", count)
+	}
+	assertLedgerMatchesBalance(t, db, userID, 0)
+}
+
+func TestAwardRejectedForUnverifiedUserIsPaidLater(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	setVerified := func(verified bool) {
+		t.Helper()
+		if _, err := db.Collection(colName).UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"email_verified": verified}}); err != nil {
+			t.Fatalf("set email_verified: %v", err)
+		}
+	}
+
+	setVerified(false)
+	_, err := LedgerServiceInstance.Award(ctx, userID, 10, model.PointsReasonDailyTask, "task")
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("Award error = %v, want %v", err, ErrEmailNotVerified)
+	}
+
+	setVerified(true)
+	if _, err := LedgerServiceInstance.Award(ctx, userID, 10, model.PointsReasonDailyTask, "task"); err != nil {
+		t.Fatalf("Award after verification: %v", err)
+	}
+	assertLedgerMatchesBalance(t, db, userID, 10)
+}
+
+func TestReverseCancelsEntryAndReleasesAwardKey(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	// An award entry whose balance update failed
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	entry := &model.PointsTransaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    objID,
+		Amount:    10,
+		Reason:    model.PointsReasonDailyTask,
+		SourceID:  "task",
+		AwardKey:  userID + ":" + model.PointsReasonDailyTask + ":task",
+		CreatedAt: time.Now(),
+	}
+	if _, err := db.Collection(ledgerColName).InsertOne(ctx, entry); err != nil {
+		t.Fatalf("insert entry: %v", err)
+	}
+
+	if err := LedgerServiceInstance.reverse(ctx, entry); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	assertLedgerMatchesBalance(t, db, userID, 0)
+
+	if _, err := LedgerServiceInstance.Award(ctx, userID, 10, model.PointsReasonDailyTask, "task"); err != nil {
+		t.Fatalf("Award after reversal: %v", err)
+	}
+	assertLedgerMatchesBalance(t, db, userID, 10)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loginAttemptRetention is how long login attempts are kept for admins
+const loginAttemptRetention = 30 * 24 * time.Hour
+
+// loginFailureWindow is how long failures are remembered without a new one
+const loginFailureWindow = time.Hour
+
+// maxLoginBackoff caps the delay between two attempts before a lockout
+const maxLoginBackoff = 5 * time.Minute
+
+// loginThrottlePolicy says how failures of one kind of key are punished
+// The first freeFailures failures cost nothing; each further one doubles the
+// wait before the next attempt (1s, 2s, 4s, ...). Reaching lockoutFailures
+// locks the key for lockout and starts the count again.
+type loginThrottlePolicy struct {
+	prefix          string
+	freeFailures    int
+	lockoutFailures int
+	lockout         time.Duration
+	lockedErr       error
+}
+
+// Per-account limits stop guessing one user's password; the looser per-IP
+// limits stop one client from trying many accounts (credential stuffing)
+var (
+	accountLoginPolicy = loginThrottlePolicy{prefix: "account:", freeFailures: 3, lockoutFailures: 10, lockout: 15 * time.Minute, lockedErr: ErrAccountLocked}
+	ipLoginPolicy      = loginThrottlePolicy{prefix: "ip:", freeFailures: 20, lockoutFailures: 100, lockout: 15 * time.Minute, lockedErr: ErrLoginThrottled}
+)
+
+// Errors returned by LoginAttemptService so controllers can map them to HTTP statuses
+// Both come wrapped in a *LoginThrottledError telling how long to wait
+var (
+	ErrLoginThrottled = errors.New("too many failed login attempts; please wait before trying again")
+	ErrAccountLocked  = errors.New("account temporarily locked after too many failed login attempts")
+)
+
+// LoginThrottledError is returned by Check while a key is backing off or locked
+// errors.Is(err, ErrLoginThrottled / ErrAccountLocked) matches it
+type LoginThrottledError struct {
+	Err       error
+	Remaining time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// RemainingSeconds rounds the wait up to whole seconds
+func (e *LoginThrottledError) RemainingSeconds() int {
+	return int((e.Remaining + time.Second - 1) / time.Second)
+}
+
+// LoginAttemptService tracks failed logins per account and per client IP
+// Every attempt is logged to login_attempts for admins; the backoff and
+// lockout state lives in login_throttles, one document per account or IP.
+// Frontend integration: Used by Login; GET /api/admin/login-attempts
+type LoginAttemptService struct {
+	attempts  *mongo.Collection // login_attempts collection
+	throttles *mongo.Collection // login_throttles collection
+}
+
+// NewLoginAttemptService creates a new LoginAttemptService instance
+func NewLoginAttemptService(attempts, throttles *mongo.Collection) *LoginAttemptService {
+	return &LoginAttemptService{attempts: attempts, throttles: throttles}
+}
+
+// EnsureIndexes creates the login attempt and throttle indexes
+//   - attempts (email, created_at), (ip, created_at), (user_id, created_at): the admin filters
+//   - attempts created_at: TTL, attempts are kept for loginAttemptRetention
+//   - throttles expires_at: TTL, state disappears after a quiet period
+//
+// Called once on startup from InitializeDB
+func (las *LoginAttemptService) EnsureIndexes(ctx context.Context) error {
+	_, err := las.attempts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}, Options: options.Index().SetExpireAfterSeconds(int32(loginAttemptRetention / time.Second))},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = las.throttles.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Check tells whether a login for email from ip may be attempted now
+// Returns a *LoginThrottledError (the longest wait of the two keys) when not
+func (las *LoginAttemptService) Check(ctx context.Context, email, ip string) error {
+	cursor, err := las.throttles.Find(ctx, bson.M{"_id": bson.M{"$in": bson.A{
+		accountLoginPolicy.key(normalizeLoginEmail(email)), ipLoginPolicy.key(ip),
+	}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var throttles []model.LoginThrottle
+	if err = cursor.All(ctx, &throttles); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var throttled *LoginThrottledError
+	for _, throttle := range throttles {
+		policy := ipLoginPolicy
+		if strings.HasPrefix(throttle.ID, accountLoginPolicy.prefix) {
+			policy = accountLoginPolicy
+		}
+
+		var wait *LoginThrottledError
+		switch {
+		case throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil):
+			wait = &LoginThrottledError{Err: policy.lockedErr, Remaining: throttle.LockedUntil.Sub(now)}
+		case throttle.NextAttemptAt != nil && now.Before(*throttle.NextAttemptAt):
+			wait = &LoginThrottledError{Err: ErrLoginThrottled, Remaining: throttle.NextAttemptAt.Sub(now)}
+		}
+		if wait != nil && (throttled == nil || wait.Remaining > throttled.Remaining) {
+			throttled = wait
+		}
+	}
+
+	if throttled != nil {
+		return throttled
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against the account and the IP
+func (las *LoginAttemptService) RecordFailure(ctx context.Context, email, ip string) error {
+	if err := las.recordFailure(ctx, accountLoginPolicy, normalizeLoginEmail(email)); err != nil {
+		return err
+	}
+	return las.recordFailure(ctx, ipLoginPolicy, ip)
+}
+
+// RecordSuccess clears the account's failures
+// The IP keeps its count, so a client cannot reset it with one known password
+func (las *LoginAttemptService) RecordSuccess(ctx context.Context, email string) error {
+	_, err := las.throttles.DeleteOne(ctx, bson.M{"_id": accountLoginPolicy.key(normalizeLoginEmail(email))})
+	return err
+}
+
+// Log stores one login attempt for the admin list
+func (las *LoginAttemptService) Log(ctx context.Context, attempt model.LoginAttempt) error {
+	attempt.ID = primitive.NewObjectID()
+	attempt.Email = normalizeLoginEmail(attempt.Email)
+	attempt.UserAgent = truncate(attempt.UserAgent, maxUserAgentLength)
+	attempt.CreatedAt = time.Now()
+
+	_, err := las.attempts.InsertOne(ctx, attempt)
+	return err
+}
+
+// List returns one page of login attempts matching filter, newest first
+// Returns: attempts for the page and the total number of matches
+func (las *LoginAttemptService) List(ctx context.Context, filter model.LoginAttemptFilter, page, limit int64) ([]model.LoginAttempt, int64, error) {
+	query := bson.M{}
+	if filter.Email != "" {
+		query["email"] = normalizeLoginEmail(filter.Email)
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.Result != "" {
+		query["result"] = filter.Result
+	}
+	if filter.From != nil || filter.To != nil {
+		createdAt := bson.M{}
+		if filter.From != nil {
+			createdAt["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			createdAt["$lt"] = *filter.To
+		}
+		query["created_at"] = createdAt
+	}
+
+	total, err := las.attempts.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := las.attempts.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var attempts []model.LoginAttempt
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return nil, 0, err
+	}
+
+	return attempts, total, nil
+}
+
+// recordFailure bumps one key's failure count and sets its backoff or lockout
+// Failures older than loginFailureWindow are forgotten (the TTL index may not
+// have removed the document yet, so the count restarts explicitly)
+func (las *LoginAttemptService) recordFailure(ctx context.Context, policy loginThrottlePolicy, value string) error {
+	now := time.Now()
+	var throttle model.LoginThrottle
+	err := las.throttles.FindOneAndUpdate(ctx,
+		bson.M{"_id": policy.key(value)},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$expires_at", now}},
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+				1,
+			}},
+			"last_failure_at": now,
+			"expires_at":      now.Add(loginFailureWindow),
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&throttle)
+	if err != nil {
+		return err
+	}
+
+	var update bson.M
+	switch {
+	case throttle.Failures >= policy.lockoutFailures:
+		lockedUntil := now.Add(policy.lockout)
+		update = bson.M{
+			"$set":   bson.M{"failures": 0, "locked_until": lockedUntil, "expires_at": lockedUntil.Add(loginFailureWindow)},
+			"$unset": bson.M{"next_attempt_at": ""},
+		}
+	case throttle.Failures > policy.freeFailures:
+		update = bson.M{"$set": bson.M{"next_attempt_at": now.Add(loginBackoff(throttle.Failures - policy.freeFailures))}}
+	default:
+		return nil
+	}
+
+	_, err = las.throttles.UpdateOne(ctx, bson.M{"_id": throttle.ID}, update)
+	return err
+}
+
+// key returns the throttle document ID for a normalized email or an IP
+func (p loginThrottlePolicy) key(value string) string {
+	return p.prefix + value
+}
+
+// loginBackoff returns the wait after the nth failure beyond the free ones
+func loginBackoff(n int) time.Duration {
+	if n > 20 {
+		return maxLoginBackoff
+	}
+	if backoff := time.Duration(1<<(n-1)) * time.Second; backoff < maxLoginBackoff {
+		return backoff
+	}
+	return maxLoginBackoff
+}
+
+// normalizeLoginEmail makes differently typed forms of one email share a counter
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
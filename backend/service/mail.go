@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"rewardpage/utils"
+	"time"
+)
+
+// mailSendTimeout bounds delivery of one email
+const mailSendTimeout = 30 * time.Second
+
+// sendMail delivers msg, logging failures
+// Run with go: the request that triggered the email has already been answered
+// kind names the email in the log ("password reset", "verification")
+func sendMail(mailer utils.MailSender, msg utils.MailMessage, kind string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+
+	if err := mailer.Send(ctx, msg); err != nil {
+		log.Printf("Warning: Failed to send %s email: %v", kind, err)
+	}
+}
+
+// appBaseURL is the frontend address used in emailed links
+// APP_BASE_URL, default the React dev server
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:5173"
+}
+
+// newSecretToken returns 32 random bytes as hex, for tokens sent to users
+func newSecretToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashSecretToken returns the hex SHA-256 under which a secret token is stored
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
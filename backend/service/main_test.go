@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"rewardpage/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDB points the service instances at a fresh database on the server in
+// MONGO_TEST_URI, dropped again when the test ends
+// Tests that need MongoDB are skipped when MONGO_TEST_URI is not set. Use a
+// replica set (e.g. mongodb://localhost:27017/?replicaSet=rs0) to cover the
+// transactional paths as well.
+func newTestDB(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := client.Database(fmt.Sprintf("rewardpage_test_%s", primitive.NewObjectID().Hex()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	mongoClient = client
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		t.Fatalf("hello: %v", err)
+	}
+	_, replicaSet := hello["setName"]
+	transactionsSupported = replicaSet || hello["msg"] == "isdbgrid"
+
+	UserServiceInstance = NewUserService(db.Collection(colName))
+	LedgerServiceInstance = NewLedgerService(db.Collection(ledgerColName), db.Collection(colName))
+	TaskTemplateServiceInstance = NewTaskTemplateService(db.Collection(taskTemplatesColName))
+	RewardServiceInstance = NewRewardService(db.Collection(rewardsColName), db.Collection(redemptionsColName), db.Collection(rewardClaimsColName))
+	if err := InitDailyTaskService(db); err != nil {
+		t.Fatalf("daily task indexes: %v", err)
+	}
+
+	if err := LedgerServiceInstance.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("ledger indexes: %v", err)
+	}
+	if err := UserServiceInstance.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("user indexes: %v", err)
+	}
+
+	return db
+}
+
+// newTestUser inserts a verified user with no points and returns its ID
+func newTestUser(t *testing.T, db *mongo.Database) string {
+	t.Helper()
+
+	id := primitive.NewObjectID()
+	_, err := db.Collection(colName).InsertOne(context.Background(), model.User{
+		ID:            id,
+		Username:      "user-" + id.Hex(),
+		Email:         id.Hex() + "@example.com",
+		Role:          "user",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return id.Hex()
+}
+
+// userPoints reads the cached balance of a user
+func userPoints(t *testing.T, db *mongo.Database, userID string) int {
+	t.Helper()
+
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	var user model.User
+	if err := db.Collection(colName).FindOne(context.Background(), bson.M{"_id": objID}).Decode(&user); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	return user.Points
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migration is a one-off data fix for documents written by older versions
+type migration struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// migrations run on startup, in order, once per database
+// A completed migration is recorded in the migrations collection and skipped
+// afterwards. Each must be idempotent: one interrupted midway (or run by two
+// instances starting together) runs again.
+var migrations = []migration{
+	{name: "ledger_opening_balances", run: func(ctx context.Context) error {
+		return LedgerServiceInstance.BackfillOpeningBalances(ctx)
+	}},
+	{name: "normalized_emails", run: func(ctx context.Context) error {
+		return UserServiceInstance.BackfillNormalizedEmails(ctx)
+	}},
+	{name: "grandfather_verified_emails", run: func(ctx context.Context) error {
+		return UserServiceInstance.GrandfatherVerifiedEmails(ctx)
+	}},
+	{name: "dedupe_daily_tasks", run: func(ctx context.Context) error {
+		return removeDuplicateDailyTasks(ctx, GetDB().Collection("daily_tasks"))
+	}},
+	{name: "sealed_totp_secrets", run: func(ctx context.Context) error {
+		return TOTPServiceInstance.SealPlainSecrets(ctx)
+	}},
+}
+
+// runMigrations applies the migrations that have not completed yet
+// Called once on startup from InitializeDB, after the services are created
+func runMigrations(ctx context.Context, collection *mongo.Collection) error {
+	for _, m := range migrations {
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": m.name})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if err := m.run(ctx); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+
+		_, err = collection.InsertOne(ctx, bson.M{"_id": m.name, "completed_at": time.Now()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		fmt.Println("Migration applied:", m.name)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// oidcLoginTTL is how long a user has to finish logging in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+// Errors returned by OIDCService so controllers can map them to HTTP statuses
+var (
+	ErrOIDCProviderNotFound  = errors.New("unknown identity provider")
+	ErrOIDCStateInvalid      = errors.New("login request is invalid or has expired; please start again")
+	ErrOIDCEmailNotVerified  = errors.New("the identity provider did not confirm your email address")
+	ErrOIDCAccountUnverified = errors.New("an account with this email exists but is not verified; log in with your password and verify your email first")
+	ErrOIDCLoginFailed       = errors.New("login with the identity provider failed")
+)
+
+// OIDCService signs users in through external OpenID Connect providers
+// Start sends the browser to the provider with a fresh state, nonce and PKCE
+// challenge; Complete redeems the code from the callback and finds the user:
+// first by an existing link to the provider account, then by verified email,
+// creating a new account when neither exists.
+// Frontend integration: GET /api/auth/oidc/{provider}/login and /callback
+type OIDCService struct {
+	states     *mongo.Collection // oidc_login_states collection
+	identities *mongo.Collection // user_identities collection
+	providers  map[string]*utils.OIDCProvider
+}
+
+// NewOIDCService creates a new OIDCService instance
+func NewOIDCService(states, identities *mongo.Collection, providers map[string]*utils.OIDCProvider) *OIDCService {
+	return &OIDCService{states: states, identities: identities, providers: providers}
+}
+
+// EnsureIndexes creates the login state and identity indexes
+//   - states expires_at: TTL, abandoned logins are removed
+//   - identities user_id: listing and removing a user's links
+//
+// Called once on startup from InitializeDB
+func (ois *OIDCService) EnsureIndexes(ctx context.Context) error {
+	_, err := ois.states.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ois.identities.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+	return err
+}
+
+// Providers returns the names of the configured providers, sorted
+func (ois *OIDCService) Providers() []string {
+	names := make([]string, 0, len(ois.providers))
+	for name := range ois.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start begins a login with provider
+// Returns the provider's authorization URL to redirect the browser to
+func (ois *OIDCService) Start(ctx context.Context, provider string) (string, error) {
+	p, ok := ois.providers[provider]
+	if !ok {
+		return "", ErrOIDCProviderNotFound
+	}
+
+	state, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := utils.NewPKCEVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	now := time.Now()
+	_, err = ois.states.InsertOne(ctx, model.OIDCLoginState{
+		ID:           hashSecretToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// Complete finishes a login from the provider's callback
+// The state is spent whether or not the login succeeds. Returns the signed-in
+// user; suspension is left to the caller.
+func (ois *OIDCService) Complete(ctx context.Context, provider, state, code string) (*model.User, error) {
+	p, ok := ois.providers[provider]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	var login model.OIDCLoginState
+	err := ois.states.FindOneAndDelete(ctx, bson.M{
+		"_id":        hashSecretToken(state),
+		"provider":   provider,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&login)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOIDCStateInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := p.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	user, err := ois.linkedUser(ctx, provider, identity)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	// Not linked yet: the email is the only thing tying the two accounts together
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	var existing model.User
+	err = UserServiceInstance.FindUserByEmail(ctx, identity.Email, &existing)
+	switch {
+	case err == nil:
+		// Someone may have registered this address without owning it; linking
+		// would hand them the provider account's logins
+		if !existing.EmailVerified {
+			return nil, ErrOIDCAccountUnverified
+		}
+		user = &existing
+	case errors.Is(err, ErrUserNotFound):
+		user, err = UserServiceInstance.CreateExternalUser(ctx, oidcUsername(identity), identity.Email)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = ois.identities.InsertOne(ctx, model.ExternalIdentity{
+		ID:          provider + ":" + identity.Subject,
+		Provider:    provider,
+		Subject:     identity.Subject,
+		UserID:      user.ID.Hex(),
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// linkedUser returns the user already linked to this provider account, or nil
+// A link whose user was deleted is removed so the account can be linked anew
+func (ois *OIDCService) linkedUser(ctx context.Context, provider string, identity *utils.OIDCIdentity) (*model.User, error) {
+	var link model.ExternalIdentity
+	err := ois.identities.FindOneAndUpdate(ctx,
+		bson.M{"_id": provider + ":" + identity.Subject},
+		bson.M{"$set": bson.M{"last_login_at": time.Now()}},
+	).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	err = UserServiceInstance.FindUserByID(ctx, link.UserID, &user)
+	if errors.Is(err, ErrUserNotFound) {
+		_, err = ois.identities.DeleteOne(ctx, bson.M{"_id": link.ID})
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// OIDCCompletionURL is the frontend page the callback redirects to
+// The result travels in the fragment (tokens, or an error code), which browsers
+// do not send to servers or put in Referer headers
+func OIDCCompletionURL(result url.Values) string {
+	return appBaseURL() + "/login/oidc#" + result.Encode()
+}
+
+// oidcUsername picks a display name for a new account from the ID token
+func oidcUsername(identity *utils.OIDCIdentity) string {
+	for _, name := range []string{identity.Username, identity.Name} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	local, _, _ := strings.Cut(identity.Email, "@")
+	return local
+}
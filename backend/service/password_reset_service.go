@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PasswordResetTTL is how long an emailed reset link works
+const PasswordResetTTL = time.Hour
+
+// passwordResetResendInterval is the minimum time between two reset emails to one user
+const passwordResetResendInterval = time.Minute
+
+// ErrResetTokenInvalid is returned for unknown, used or expired reset tokens
+var ErrResetTokenInvalid = errors.New("password reset link is invalid or has expired")
+
+// PasswordResetService issues and redeems password reset tokens
+// The token is only ever emailed; the database keeps its SHA-256. Redeeming a
+// token sets the new password, spends every outstanding token of the user and
+// revokes all of their sessions.
+// Frontend integration: POST /api/users/password/forgot and /password/reset
+type PasswordResetService struct {
+	collection *mongo.Collection // password_reset_tokens collection
+	mailer     utils.MailSender
+}
+
+// NewPasswordResetService creates a new PasswordResetService instance
+func NewPasswordResetService(collection *mongo.Collection, mailer utils.MailSender) *PasswordResetService {
+	return &PasswordResetService{collection: collection, mailer: mailer}
+}
+
+// EnsureIndexes creates the reset token indexes
+//   - user_id: throttling and spending a user's other tokens
+//   - expires_at: TTL, expired tokens are removed
+//
+// Called once on startup from InitializeDB
+func (prs *PasswordResetService) EnsureIndexes(ctx context.Context) error {
+	_, err := prs.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// RequestReset emails a reset link to the account with this email
+// Unknown emails and suspended accounts are silently ignored, and so are
+// requests within passwordResetResendInterval of the last one, so the caller
+// cannot tell whether an account exists. The email is sent in the background.
+func (prs *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	var user model.User
+	err := UserServiceInstance.FindUserByEmail(ctx, email, &user)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Suspended {
+		return nil
+	}
+
+	userID := user.ID.Hex()
+	now := time.Now()
+	recent, err := prs.collection.CountDocuments(ctx, bson.M{
+		"user_id":    userID,
+		"created_at": bson.M{"$gt": now.Add(-passwordResetResendInterval)},
+	})
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the newest link works
+	if _, err := prs.collection.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	_, err = prs.collection.InsertOne(ctx, model.PasswordResetToken{
+		ID:        hashSecretToken(token),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+	msg := utils.MailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"Open this link within %d minutes to choose a new one:\n\n%s\n\n"+
+			"If this was not you, ignore this email; your password stays unchanged.\n",
+			user.Username, int(PasswordResetTTL.Minutes()), link),
+	}
+	go sendMail(prs.mailer, msg, "password reset")
+
+	return nil
+}
+
+// ResetPassword sets a new password using an emailed token
+// The password is validated before the token is spent, so a rejected password
+// can be retried with the same link. Returns ErrResetTokenInvalid for unknown,
+// used or expired tokens.
+func (prs *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var reset model.PasswordResetToken
+	err := prs.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": hashSecretToken(token), "used_at": nil, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return ErrResetTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := UserServiceInstance.SetPassword(ctx, reset.UserID, newPassword); err != nil {
+		return err
+	}
+	if _, err := prs.collection.DeleteMany(ctx, bson.M{"user_id": reset.UserID, "_id": bson.M{"$ne": reset.ID}}); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password is signed out everywhere
+	_, err = RefreshTokenServiceInstance.RevokeAllForUser(ctx, reset.UserID, RevokeReasonPasswordReset)
+	return err
+}
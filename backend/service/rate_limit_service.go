@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitService counts requests in MongoDB so every API instance shares one budget
+// Fixed windows: one counter document per key and window, incremented
+// atomically and removed by a TTL index after the window. Selected with
+// RATE_LIMIT_STORE=mongo; implements middleware.RateLimitStore.
+type RateLimitService struct {
+	collection *mongo.Collection // rate_limits collection
+}
+
+// NewRateLimitService creates a new RateLimitService instance
+func NewRateLimitService(collection *mongo.Collection) *RateLimitService {
+	return &RateLimitService{collection: collection}
+}
+
+// EnsureIndexes creates the TTL index that drops counters of past windows
+// Called once on startup from InitializeDB
+func (rls *RateLimitService) EnsureIndexes(ctx context.Context) error {
+	_, err := rls.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Take counts one request for key in the current window
+// Returns whether it is within limit, how many requests remain and how long
+// until the window ends
+func (rls *RateLimitService) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	windowEnd := windowStart.Add(window)
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := rls.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key + ":" + strconv.FormatInt(windowStart.Unix(), 10)},
+		bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"expires_at": windowEnd},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	remaining := limit - counter.Count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return counter.Count <= limit, remaining, windowEnd.Sub(now), nil
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"rewardpage/model"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReferralBonusPoints is credited to the referrer for each invited user who
+// joins and verifies their email
+// Frontend: referPoints.jsx shows "earn 25 pts when they verify their email"
+const ReferralBonusPoints = 25
+
+// referralCodeAlphabet skips characters that are easy to misread (0/O, 1/I)
+const referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const referralCodeLength = 8
+
+// Errors returned when a referral cannot be accepted at registration
+var (
+	ErrInvalidReferralCode = errors.New("invalid referral code")
+	ErrSelfReferral        = errors.New("you cannot use your own referral code")
+	ErrReferralEmailUsed   = errors.New("this email has already been registered or referred")
+)
+
+// ReferralService handles invite codes and referral attribution
+// Frontend integration: Called by referral_controller and Create1user
+type ReferralService struct {
+	collection *mongo.Collection // referrals collection
+	users      *mongo.Collection // users collection (referral codes live on the user)
+}
+
+// NewReferralService creates a new ReferralService instance
+func NewReferralService(collection, users *mongo.Collection) *ReferralService {
+	return &ReferralService{collection: collection, users: users}
+}
+
+// EnsureIndexes creates the unique indexes that back the anti-farming rules
+// - referrals.referee_id: a user can only be referred once
+// - referrals.referee_email: a mailbox (normalized) can only be referred once
+// - users.referral_code: codes are unique; sparse because older users have none yet
+// Called once on startup from InitializeDB
+func (rs *ReferralService) EnsureIndexes(ctx context.Context) error {
+	_, err := rs.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "referee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "referee_email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "referrer_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = rs.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "referral_code", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	return err
+}
+
+// ResolveReferrer looks up the owner of a referral code for a new registration
+// Rejects:
+// - unknown codes
+// - self-referral (the code belongs to the same mailbox)
+// - mailboxes that were already registered or referred, including +tag/dot aliases
+func (rs *ReferralService) ResolveReferrer(ctx context.Context, code, refereeEmail string) (*model.User, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, ErrInvalidReferralCode
+	}
+
+	var referrer model.User
+	err := rs.users.FindOne(ctx, bson.M{"referral_code": code}).Decode(&referrer)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidReferralCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := NormalizeEmail(refereeEmail)
+	if NormalizeEmail(referrer.Email) == normalized {
+		return nil, ErrSelfReferral
+	}
+
+	registered, err := rs.users.CountDocuments(ctx, bson.M{"normalized_email": normalized})
+	if err != nil {
+		return nil, err
+	}
+	referred, err := rs.collection.CountDocuments(ctx, bson.M{"referee_email": normalized})
+	if err != nil {
+		return nil, err
+	}
+	if registered > 0 || referred > 0 {
+		return nil, ErrReferralEmailUsed
+	}
+
+	return &referrer, nil
+}
+
+// Attribute stores the referral
+// Called right after the referee's account is created. The referrer is paid
+// by SettleVerified once the referee has verified their email.
+func (rs *ReferralService) Attribute(ctx context.Context, referrer, referee *model.User) (*model.Referral, error) {
+	referral := &model.Referral{
+		ID:           primitive.NewObjectID(),
+		ReferrerID:   referrer.ID,
+		RefereeID:    referee.ID,
+		RefereeEmail: NormalizeEmail(referee.Email),
+		CreatedAt:    time.Now(),
+	}
+
+	if _, err := rs.collection.InsertOne(ctx, referral); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrReferralEmailUsed
+		}
+		return nil, err
+	}
+
+	_, err := rs.users.UpdateOne(ctx, bson.M{"_id": referee.ID}, bson.M{
+		"$set": bson.M{"referred_by": referrer.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return referral, nil
+}
+
+// SettleVerified pays the referral bonuses unlocked by userID verifying their email
+// Marks the user's own referral as verified, then pays every unpaid referral
+// with a verified referee where the user is either side. A referrer who has
+// not verified yet is skipped and paid when they verify.
+// Called by EmailVerificationService.Verify
+func (rs *ReferralService) SettleVerified(ctx context.Context, userID primitive.ObjectID) error {
+	now := time.Now()
+	_, err := rs.collection.UpdateMany(ctx,
+		bson.M{"referee_id": userID, "referee_verified_at": nil},
+		bson.M{"$set": bson.M{"referee_verified_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// points_awarded 0: referrals attributed before payouts waited for
+	// verification were paid at registration
+	cursor, err := rs.collection.Find(ctx, bson.M{
+		"$or":                 bson.A{bson.M{"referee_id": userID}, bson.M{"referrer_id": userID}},
+		"referee_verified_at": bson.M{"$ne": nil},
+		"paid_at":             nil,
+		"points_awarded":      0,
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var pending []model.Referral
+	if err = cursor.All(ctx, &pending); err != nil {
+		return err
+	}
+
+	for _, referral := range pending {
+		_, err := LedgerServiceInstance.Award(ctx, referral.ReferrerID.Hex(), ReferralBonusPoints, model.PointsReasonReferral, referral.RefereeID.Hex())
+		if errors.Is(err, ErrEmailNotVerified) {
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrAlreadyAwarded) {
+			return err
+		}
+
+		_, err = rs.collection.UpdateOne(ctx, bson.M{"_id": referral.ID}, bson.M{
+			"$set": bson.M{"paid_at": now, "points_awarded": ReferralBonusPoints},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetStats returns a user's referral code, number of referrals and points earned
+// Used by frontend GET /api/referrals endpoint
+func (rs *ReferralService) GetStats(ctx context.Context, userID string) (map[string]interface{}, error) {
+	code, err := rs.EnsureReferralCode(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"referrer_id": userObjID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          nil,
+			"count":        bson.M{"$sum": 1},
+			"pointsEarned": bson.M{"$sum": "$points_awarded"},
+			"pending": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$points_awarded", 0}}, 1, 0,
+			}}},
+		}}},
+	}
+
+	cursor, err := rs.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Count        int `bson:"count"`
+		PointsEarned int `bson:"pointsEarned"`
+		Pending      int `bson:"pending"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	count, pointsEarned, pending := 0, 0, 0
+	if len(result) > 0 {
+		count, pointsEarned, pending = result[0].Count, result[0].PointsEarned, result[0].Pending
+	}
+
+	return map[string]interface{}{
+		"referralCode":      code,
+		"referralCount":     count,
+		"pointsEarned":      pointsEarned,
+		"pendingReferrals":  pending, // Waiting for an email verification
+		"pointsPerReferral": ReferralBonusPoints,
+	}, nil
+}
+
+// EnsureReferralCode returns the user's referral code, assigning one if missing
+// Users created before the referral program have no code until first asked
+func (rs *ReferralService) EnsureReferralCode(ctx context.Context, userID string) (string, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID")
+	}
+
+	var user model.User
+	if err := rs.users.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	if user.ReferralCode != "" {
+		return user.ReferralCode, nil
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := generateReferralCode()
+		if err != nil {
+			return "", err
+		}
+
+		// Only set when still missing, so concurrent calls agree on one code
+		_, err = rs.users.UpdateOne(ctx,
+			bson.M{"_id": userObjID, "referral_code": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"referral_code": code}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if err := rs.users.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user); err != nil {
+			return "", err
+		}
+		return user.ReferralCode, nil
+	}
+
+	return "", fmt.Errorf("could not generate referral code")
+}
+
+// NormalizeEmail reduces an address to its mailbox so aliases compare equal
+// - lowercases the whole address
+// - drops "+tag" suffixes from the local part
+// - drops dots from Gmail local parts (Gmail ignores them)
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+
+	return local + "@" + domain
+}
+
+// generateReferralCode returns a random code from referralCodeAlphabet
+func generateReferralCode() (string, error) {
+	buf := make([]byte, referralCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, referralCodeLength)
+	for i, b := range buf {
+		code[i] = referralCodeAlphabet[int(b)%len(referralCodeAlphabet)]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RefreshTokenTTL is how long a refresh token stays usable
+// Every rotation starts a new period, so an active login never expires
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// Reasons stored when a refresh token family is revoked
+const (
+	RevokeReasonLogout         = "logout"
+	RevokeReasonLogoutAll      = "logout_all"
+	RevokeReasonReuseDetected  = "reuse_detected"
+	RevokeReasonSuspended      = "suspended"
+	RevokeReasonPasswordReset  = "password_reset"
+	RevokeReasonPasswordChange = "password_change"
+)
+
+// sessionTouchInterval limits how often an access token request updates last_used_at
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength bounds the stored User-Agent header
+const maxUserAgentLength = 256
+
+// Errors returned by RefreshTokenService so controllers can map them to HTTP statuses
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; please log in again")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionRevoked      = errors.New("session has been revoked; please log in again")
+)
+
+// RefreshTokenService persists refresh token families
+// A family is started at login and holds the ID (jti) of the only refresh token
+// that may be used next. Each refresh replaces that ID, so every refresh token
+// works exactly once. Presenting an older token of the family means it leaked:
+// the whole family is revoked and the user has to log in again.
+// Frontend integration: Used by auth_controller for login, refresh and logout
+type RefreshTokenService struct {
+	collection *mongo.Collection // refresh_token_families collection
+}
+
+// NewRefreshTokenService creates a new RefreshTokenService instance
+func NewRefreshTokenService(collection *mongo.Collection) *RefreshTokenService {
+	return &RefreshTokenService{collection: collection}
+}
+
+// EnsureIndexes creates the refresh token family indexes
+//   - user_id: revoking every family of a user
+//   - expires_at: TTL, families disappear once their last token has expired
+//
+// Called once on startup from InitializeDB
+func (rts *RefreshTokenService) EnsureIndexes(ctx context.Context) error {
+	_, err := rts.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// StartFamily begins a new family (session) for a fresh login
+// userAgent and ip describe the device so users can recognise their sessions
+// Returns the family; CurrentTokenID and ExpiresAt go into the refresh token
+func (rts *RefreshTokenService) StartFamily(ctx context.Context, userID, userAgent, ip string) (*model.RefreshTokenFamily, error) {
+	familyID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	tokenID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	family := &model.RefreshTokenFamily{
+		ID:             familyID,
+		UserID:         userID,
+		CurrentTokenID: tokenID,
+		UserAgent:      truncate(userAgent, maxUserAgentLength),
+		IP:             ip,
+		CreatedAt:      now,
+		LastUsedAt:     now,
+		ExpiresAt:      now.Add(RefreshTokenTTL),
+	}
+
+	if _, err := rts.collection.InsertOne(ctx, family); err != nil {
+		return nil, err
+	}
+	return family, nil
+}
+
+// Rotate exchanges the family's current token for a new one
+// tokenID is the jti of the presented refresh token; ip is recorded as the
+// session's latest address. Returns the updated family with the new CurrentTokenID.
+// Returns ErrRefreshTokenReused (and revokes the family) when tokenID was
+// already rotated out, or ErrRefreshTokenInvalid when the family is unknown,
+// revoked or expired.
+func (rts *RefreshTokenService) Rotate(ctx context.Context, userID, familyID, tokenID, ip string) (*model.RefreshTokenFamily, error) {
+	newID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var family model.RefreshTokenFamily
+	err = rts.collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":              familyID,
+			"user_id":          userID,
+			"current_token_id": tokenID,
+			"revoked_at":       nil,
+			"expires_at":       bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{
+			"current_token_id": newID,
+			"ip":               ip,
+			"last_used_at":     now,
+			"expires_at":       now.Add(RefreshTokenTTL),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&family)
+	if err == nil {
+		return &family, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// Work out why the swap failed
+	err = rts.collection.FindOne(ctx, bson.M{"_id": familyID, "user_id": userID}).Decode(&family)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if family.RevokedAt != nil || !family.ExpiresAt.After(now) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// The family is live but the token is not its current one: replay
+	if _, err := rts.revoke(ctx, bson.M{"_id": familyID}, RevokeReasonReuseDetected); err != nil {
+		return nil, err
+	}
+	return nil, ErrRefreshTokenReused
+}
+
+// RevokeFamily ends one login of a user
+// Revoking an already revoked or expired family is not an error (used by logout)
+func (rts *RefreshTokenService) RevokeFamily(ctx context.Context, userID, familyID string) error {
+	_, err := rts.revoke(ctx, bson.M{"_id": familyID, "user_id": userID}, RevokeReasonLogout)
+	return err
+}
+
+// ListSessions returns the user's live sessions, most recently used first
+func (rts *RefreshTokenService) ListSessions(ctx context.Context, userID string) ([]model.RefreshTokenFamily, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := rts.collection.Find(ctx, bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []model.RefreshTokenFamily
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions by ID
+// Returns ErrSessionNotFound when the user has no live session with that ID
+func (rts *RefreshTokenService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	revoked, err := rts.revoke(ctx, bson.M{"_id": sessionID, "user_id": userID}, RevokeReasonLogout)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// CheckSession verifies that an access token's session is still live
+// Called by AuthMiddleware on every request carrying a sid claim; also bumps
+// last_used_at, at most once per sessionTouchInterval
+// Returns ErrSessionRevoked when the session was revoked, expired or deleted
+func (rts *RefreshTokenService) CheckSession(ctx context.Context, userID, sessionID string) error {
+	var session model.RefreshTokenFamily
+	err := rts.collection.FindOne(ctx, bson.M{"_id": sessionID, "user_id": userID},
+		options.FindOne().SetProjection(bson.M{"revoked_at": 1, "expires_at": 1, "last_used_at": 1}),
+	).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return ErrSessionRevoked
+	}
+
+	if now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		_, err = rts.collection.UpdateOne(ctx,
+			bson.M{"_id": sessionID, "last_used_at": bson.M{"$lt": now.Add(-sessionTouchInterval)}},
+			bson.M{"$set": bson.M{"last_used_at": now}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeAllForUser ends every login of a user
+// Returns the number of families revoked
+func (rts *RefreshTokenService) RevokeAllForUser(ctx context.Context, userID, reason string) (int64, error) {
+	result, err := rts.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoke_reason": reason}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// RevokeOtherSessions ends every login of a user except keepSessionID
+// Used after a password change so only the device that made it stays signed in
+// Returns the number of families revoked
+func (rts *RefreshTokenService) RevokeOtherSessions(ctx context.Context, userID, keepSessionID, reason string) (int64, error) {
+	result, err := rts.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "_id": bson.M{"$ne": keepSessionID}, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoke_reason": reason}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// revoke marks the matching live family as revoked
+// Returns whether a live family was found
+func (rts *RefreshTokenService) revoke(ctx context.Context, filter bson.M, reason string) (bool, error) {
+	now := time.Now()
+	filter["revoked_at"] = nil
+	filter["expires_at"] = bson.M{"$gt": now}
+	result, err := rts.collection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"revoked_at": now, "revoke_reason": reason},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// truncate shortens s to at most max bytes
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// newTokenID returns 16 random bytes as hex, used for family and token IDs
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
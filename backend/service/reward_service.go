@@ -0,0 +1,512 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by RewardService so controllers can map them to HTTP statuses
+var (
+	ErrRewardNotFound          = errors.New("reward not found")
+	ErrRewardUnavailable       = errors.New("reward is not available")
+	ErrRewardOutOfStock        = errors.New("reward is out of stock")
+	ErrRedemptionLimitReached  = errors.New("redemption limit reached for this reward")
+	ErrRedemptionNotFound      = errors.New("redemption not found")
+	ErrInvalidStatusTransition = errors.New("invalid redemption status transition")
+	ErrInvalidRewardInput      = errors.New("invalid reward")
+)
+
+// redemptionTransitions lists the statuses each redemption status may move to
+var redemptionTransitions = map[string][]string{
+	model.RedemptionStatusPending:   {model.RedemptionStatusFulfilled, model.RedemptionStatusCancelled},
+	model.RedemptionStatusFulfilled: {model.RedemptionStatusRefunded},
+}
+
+// RewardService handles the reward catalog and point redemptions
+// Frontend integration: Called by reward_controller for the "Redeem Rewards" tab
+type RewardService struct {
+	collection  *mongo.Collection // rewards collection (catalog)
+	redemptions *mongo.Collection // redemptions collection
+	claims      *mongo.Collection // reward_claims collection (per-user redemption counters)
+}
+
+// NewRewardService creates a new RewardService instance
+func NewRewardService(collection, redemptions, claims *mongo.Collection) *RewardService {
+	return &RewardService{collection: collection, redemptions: redemptions, claims: claims}
+}
+
+// EnsureIndexes creates indexes used for listing a user's redemptions
+// Called once on startup from InitializeDB
+func (rs *RewardService) EnsureIndexes(ctx context.Context) error {
+	_, err := rs.redemptions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	return err
+}
+
+// ListRewards returns catalog items sorted by point cost
+// Parameters:
+// - activeOnly: true for the user-facing catalog, false for the admin view
+func (rs *RewardService) ListRewards(ctx context.Context, activeOnly bool) ([]model.Reward, error) {
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "point_cost", Value: 1}, {Key: "title", Value: 1}})
+	cursor, err := rs.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rewards []model.Reward
+	if err = cursor.All(ctx, &rewards); err != nil {
+		return nil, err
+	}
+
+	return rewards, nil
+}
+
+// GetReward retrieves a single catalog item by ID
+func (rs *RewardService) GetReward(ctx context.Context, rewardID string) (*model.Reward, error) {
+	objID, err := primitive.ObjectIDFromHex(rewardID)
+	if err != nil {
+		return nil, ErrRewardNotFound
+	}
+
+	var reward model.Reward
+	err = rs.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&reward)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRewardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &reward, nil
+}
+
+// CreateReward adds a new catalog item (admin only)
+func (rs *RewardService) CreateReward(ctx context.Context, input model.RewardInput) (*model.Reward, error) {
+	if err := validateRewardInput(input); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reward := &model.Reward{
+		ID:           primitive.NewObjectID(),
+		Title:        input.Title,
+		Description:  input.Description,
+		PointCost:    input.PointCost,
+		Stock:        input.Stock,
+		PerUserLimit: input.PerUserLimit,
+		Active:       input.Active,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if _, err := rs.collection.InsertOne(ctx, reward); err != nil {
+		return nil, err
+	}
+
+	return reward, nil
+}
+
+// UpdateReward replaces the editable fields of a catalog item (admin only)
+func (rs *RewardService) UpdateReward(ctx context.Context, rewardID string, input model.RewardInput) (*model.Reward, error) {
+	if err := validateRewardInput(input); err != nil {
+		return nil, err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(rewardID)
+	if err != nil {
+		return nil, ErrRewardNotFound
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"title":          input.Title,
+			"description":    input.Description,
+			"point_cost":     input.PointCost,
+			"stock":          input.Stock,
+			"per_user_limit": input.PerUserLimit,
+			"active":         input.Active,
+			"updated_at":     time.Now(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var reward model.Reward
+	err = rs.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, opts).Decode(&reward)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRewardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &reward, nil
+}
+
+// DeleteReward removes a catalog item (admin only)
+// Existing redemptions keep their copied title and cost
+func (rs *RewardService) DeleteReward(ctx context.Context, rewardID string) error {
+	objID, err := primitive.ObjectIDFromHex(rewardID)
+	if err != nil {
+		return ErrRewardNotFound
+	}
+
+	result, err := rs.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRewardNotFound
+	}
+
+	return nil
+}
+
+// Redeem spends a user's points on a reward
+// Called by frontend POST /api/rewards/{id}/redeem endpoint
+// Every step is a conditional update so concurrent redemptions cannot oversell:
+// 1. Claim a per-user slot (only if under PerUserLimit)
+// 2. Decrement stock (only if stock > 0)
+// 3. Debit points through the ledger (only if points >= cost)
+// 4. Insert the pending redemption
+// The steps run in one transaction, so a failure rolls back the ones before it.
+// Without transaction support they are undone one by one (see undoRedeem).
+func (rs *RewardService) Redeem(ctx context.Context, userID, rewardID string) (*model.Redemption, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	reward, err := rs.GetReward(ctx, rewardID)
+	if err != nil {
+		return nil, err
+	}
+	if !reward.Active {
+		return nil, ErrRewardUnavailable
+	}
+
+	var redemption *model.Redemption
+	err = runInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		redemption, err = rs.redeem(ctx, userObjID, reward)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// redeem runs the steps of Redeem
+func (rs *RewardService) redeem(ctx context.Context, userObjID primitive.ObjectID, reward *model.Reward) (*model.Redemption, error) {
+	// Step 1: per-user limit
+	if reward.PerUserLimit > 0 {
+		if err := rs.claimSlot(ctx, userObjID, reward); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 2: stock
+	result, err := rs.collection.UpdateOne(ctx,
+		bson.M{"_id": reward.ID, "active": true, "stock": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"stock": -1}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		err = ErrRewardOutOfStock
+	}
+	if err != nil {
+		return nil, rs.undoRedeem(ctx, userObjID, reward, err, false, nil)
+	}
+
+	// Step 3: points
+	now := time.Now()
+	redemption := &model.Redemption{
+		ID:          primitive.NewObjectID(),
+		UserID:      userObjID,
+		RewardID:    reward.ID,
+		RewardTitle: reward.Title,
+		PointCost:   reward.PointCost,
+		Status:      model.RedemptionStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if reward.PointCost > 0 {
+		_, err = LedgerServiceInstance.Debit(ctx, userObjID.Hex(), reward.PointCost, model.PointsReasonRedemption, redemption.ID.Hex())
+		if err != nil {
+			return nil, rs.undoRedeem(ctx, userObjID, reward, err, true, nil)
+		}
+	}
+
+	// Step 4: redemption record
+	if _, err := rs.redemptions.InsertOne(ctx, redemption); err != nil {
+		return nil, rs.undoRedeem(ctx, userObjID, reward, err, true, redemption)
+	}
+
+	return redemption, nil
+}
+
+// undoRedeem gives back what a failed redemption already took after err:
+// the points debited for redemption (when not nil), the unit of stock (when
+// restock) and the per-user slot
+// Inside a transaction it only returns err, the abort undoes the steps.
+func (rs *RewardService) undoRedeem(ctx context.Context, userObjID primitive.ObjectID, reward *model.Reward, err error, restock bool, redemption *model.Redemption) error {
+	step := "redeem " + reward.ID.Hex() + ": "
+	if redemption != nil && reward.PointCost > 0 {
+		err = compensate(ctx, step+"refund points", err, func() error {
+			_, err := LedgerServiceInstance.Record(ctx, userObjID.Hex(), reward.PointCost, model.PointsReasonRefund, redemption.ID.Hex())
+			return err
+		})
+	}
+	if restock {
+		err = compensate(ctx, step+"restock", err, func() error {
+			return rs.restock(ctx, reward.ID)
+		})
+	}
+	return compensate(ctx, step+"release slot", err, func() error {
+		return rs.releaseSlot(ctx, userObjID, reward)
+	})
+}
+
+// GetRedemptionsByUserID returns a user's redemptions, newest first
+func (rs *RewardService) GetRedemptionsByUserID(ctx context.Context, userID string) ([]model.Redemption, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	return rs.findRedemptions(ctx, bson.M{"user_id": userObjID})
+}
+
+// GetRedemption retrieves a single redemption by ID
+func (rs *RewardService) GetRedemption(ctx context.Context, redemptionID string) (*model.Redemption, error) {
+	objID, err := primitive.ObjectIDFromHex(redemptionID)
+	if err != nil {
+		return nil, ErrRedemptionNotFound
+	}
+
+	var redemption model.Redemption
+	err = rs.redemptions.FindOne(ctx, bson.M{"_id": objID}).Decode(&redemption)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRedemptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &redemption, nil
+}
+
+// ListRedemptions returns all redemptions, optionally filtered by status (admin only)
+func (rs *RewardService) ListRedemptions(ctx context.Context, status string) ([]model.Redemption, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	return rs.findRedemptions(ctx, filter)
+}
+
+// UpdateRedemptionStatus moves a redemption through its lifecycle (admin only)
+// Allowed transitions:
+// - pending -> fulfilled
+// - pending -> cancelled (points returned, stock restored)
+// - fulfilled -> refunded (points returned)
+// The status check and update are one conditional write, so a redemption can
+// only be refunded or cancelled once. The points are returned together with the
+// status change (see transition), so a failed refund can simply be retried.
+func (rs *RewardService) UpdateRedemptionStatus(ctx context.Context, redemptionID, status string) (*model.Redemption, error) {
+	objID, err := primitive.ObjectIDFromHex(redemptionID)
+	if err != nil {
+		return nil, ErrRedemptionNotFound
+	}
+
+	var current model.Redemption
+	err = rs.redemptions.FindOne(ctx, bson.M{"_id": objID}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRedemptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !canTransition(current.Status, status) {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	var updated *model.Redemption
+	err = runInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = rs.transition(ctx, &current, status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Stock and the per-user slot are given back once the points are; a failure
+	// there leaves the redemption and refund in place and is only logged
+	if status == model.RedemptionStatusCancelled {
+		if err := rs.restock(ctx, updated.RewardID); err != nil {
+			log.Printf("redemption %s: restock: %v", updated.ID.Hex(), err)
+		}
+	}
+	if status == model.RedemptionStatusCancelled || status == model.RedemptionStatusRefunded {
+		if err := rs.releaseRedemptionSlot(ctx, updated); err != nil {
+			log.Printf("redemption %s: release slot: %v", updated.ID.Hex(), err)
+		}
+	}
+
+	return updated, nil
+}
+
+// transition moves current to status and returns the points of a cancelled or
+// refunded redemption
+// Returns ErrInvalidStatusTransition when the status changed meanwhile. When the
+// refund fails the status change is reverted (or the transaction aborted).
+func (rs *RewardService) transition(ctx context.Context, current *model.Redemption, status string) (*model.Redemption, error) {
+	now := time.Now()
+	var updated model.Redemption
+	err := rs.redemptions.FindOneAndUpdate(ctx,
+		bson.M{"_id": current.ID, "status": current.Status},
+		bson.M{"$set": bson.M{"status": status, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		// Someone else moved it first
+		return nil, ErrInvalidStatusTransition
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refund := status == model.RedemptionStatusCancelled || status == model.RedemptionStatusRefunded
+	if refund && updated.PointCost > 0 {
+		_, err := LedgerServiceInstance.Record(ctx, updated.UserID.Hex(), updated.PointCost, model.PointsReasonRefund, updated.ID.Hex())
+		if err != nil {
+			return nil, compensate(ctx, "redemption "+updated.ID.Hex()+": revert status", err, func() error {
+				_, err := rs.redemptions.UpdateOne(ctx,
+					bson.M{"_id": updated.ID, "status": status, "updated_at": now},
+					bson.M{"$set": bson.M{"status": current.Status, "updated_at": current.UpdatedAt}},
+				)
+				return err
+			})
+		}
+	}
+
+	return &updated, nil
+}
+
+// releaseRedemptionSlot gives back the per-user slot a redemption took
+// The reward's current limit decides; a deleted reward has no slots to return
+func (rs *RewardService) releaseRedemptionSlot(ctx context.Context, redemption *model.Redemption) error {
+	reward, err := rs.GetReward(ctx, redemption.RewardID.Hex())
+	if errors.Is(err, ErrRewardNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return rs.releaseSlot(ctx, redemption.UserID, reward)
+}
+
+// claimSlot increments the user's redemption counter for a reward if under the limit
+// The upsert collides on _id when the counter is already at the limit, which
+// makes the check-and-increment atomic.
+func (rs *RewardService) claimSlot(ctx context.Context, userObjID primitive.ObjectID, reward *model.Reward) error {
+	_, err := rs.claims.UpdateOne(ctx,
+		bson.M{"_id": claimKey(userObjID, reward.ID), "count": bson.M{"$lt": reward.PerUserLimit}},
+		bson.M{"$inc": bson.M{"count": 1}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrRedemptionLimitReached
+	}
+	return err
+}
+
+// releaseSlot gives back a per-user slot after a failed, cancelled or refunded redemption
+func (rs *RewardService) releaseSlot(ctx context.Context, userObjID primitive.ObjectID, reward *model.Reward) error {
+	if reward.PerUserLimit <= 0 {
+		return nil
+	}
+	_, err := rs.claims.UpdateOne(ctx,
+		bson.M{"_id": claimKey(userObjID, reward.ID), "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	return err
+}
+
+// restock puts one unit back into a reward's stock
+func (rs *RewardService) restock(ctx context.Context, rewardID primitive.ObjectID) error {
+	_, err := rs.collection.UpdateOne(ctx,
+		bson.M{"_id": rewardID},
+		bson.M{"$inc": bson.M{"stock": 1}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+// findRedemptions runs a redemption query sorted newest first
+func (rs *RewardService) findRedemptions(ctx context.Context, filter bson.M) ([]model.Redemption, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := rs.redemptions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var redemptions []model.Redemption
+	if err = cursor.All(ctx, &redemptions); err != nil {
+		return nil, err
+	}
+
+	return redemptions, nil
+}
+
+// claimKey identifies the per-user counter document for a reward
+func claimKey(userObjID, rewardID primitive.ObjectID) string {
+	return userObjID.Hex() + ":" + rewardID.Hex()
+}
+
+// canTransition reports whether a redemption may move from one status to another
+func canTransition(from, to string) bool {
+	for _, allowed := range redemptionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRewardInput checks admin-supplied catalog fields
+func validateRewardInput(input model.RewardInput) error {
+	if input.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidRewardInput)
+	}
+	if input.PointCost < 0 {
+		return fmt.Errorf("%w: pointCost must not be negative", ErrInvalidRewardInput)
+	}
+	if input.Stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidRewardInput)
+	}
+	if input.PerUserLimit < 0 {
+		return fmt.Errorf("%w: perUserLimit must not be negative", ErrInvalidRewardInput)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCancelRedemptionReturnsPointsStockAndSlot(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	if _, err := db.Collection(colName).UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"points": 10}}); err != nil {
+		t.Fatalf("set points: %v", err)
+	}
+	reward, err := RewardServiceInstance.CreateReward(ctx, model.RewardInput{Title: "Mug", PointCost: 10, Stock: 1, PerUserLimit: 1, Active: true})
+	if err != nil {
+		t.Fatalf("CreateReward: %v", err)
+	}
+
+	redemption, err := RewardServiceInstance.Redeem(ctx, userID, reward.ID.Hex())
+	if err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if got := userPoints(t, db, userID); got != 0 {
+		t.Fatalf("points after redeeming = %d, want 0", got)
+	}
+
+	updated, err := RewardServiceInstance.UpdateRedemptionStatus(ctx, redemption.ID.Hex(), model.RedemptionStatusCancelled)
+	if err != nil {
+		t.Fatalf("UpdateRedemptionStatus: %v", err)
+	}
+	if updated.Status != model.RedemptionStatusCancelled {
+		t.Errorf("status = %q, want %q", updated.Status, model.RedemptionStatusCancelled)
+	}
+	if got := userPoints(t, db, userID); got != 10 {
+		t.Errorf("points after cancelling = %d, want 10", got)
+	}
+
+	// Cancelling twice must not refund twice
+	_, err = RewardServiceInstance.UpdateRedemptionStatus(ctx, redemption.ID.Hex(), model.RedemptionStatusCancelled)
+	if !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("second cancel error = %v, want %v", err, ErrInvalidStatusTransition)
+	}
+	if got := userPoints(t, db, userID); got != 10 {
+		t.Errorf("points after second cancel = %d, want 10", got)
+	}
+
+	// Stock and the per-user slot are back, so the reward can be redeemed again
+	if _, err := RewardServiceInstance.Redeem(ctx, userID, reward.ID.Hex()); err != nil {
+		t.Errorf("Redeem after cancelling: %v", err)
+	}
+}
+
+func TestRedeemWithoutEnoughPointsTakesNothing(t *testing.T) {
+	db := newTestDB(t)
+	userID := newTestUser(t, db)
+	ctx := context.Background()
+
+	objID, _ := primitive.ObjectIDFromHex(userID)
+	setPoints := func(points int) {
+		t.Helper()
+		if _, err := db.Collection(colName).UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"points": points}}); err != nil {
+			t.Fatalf("set points: %v", err)
+		}
+	}
+	setPoints(5)
+	reward, err := RewardServiceInstance.CreateReward(ctx, model.RewardInput{Title: "Mug", PointCost: 10, Stock: 1, PerUserLimit: 1, Active: true})
+	if err != nil {
+		t.Fatalf("CreateReward: %v", err)
+	}
+
+	if _, err := RewardServiceInstance.Redeem(ctx, userID, reward.ID.Hex()); !errors.Is(err, ErrInsufficientPoints) {
+		t.Fatalf("Redeem error = %v, want %v", err, ErrInsufficientPoints)
+	}
+
+	stored, err := RewardServiceInstance.GetReward(ctx, reward.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetReward: %v", err)
+	}
+	if stored.Stock != 1 {
+		t.Errorf("stock after failed redemption = %d, want 1", stored.Stock)
+	}
+	redemptions, err := RewardServiceInstance.GetRedemptionsByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetRedemptionsByUserID: %v", err)
+	}
+	if len(redemptions) != 0 {
+		t.Errorf("redemptions after failed redemption = %d, want 0", len(redemptions))
+	}
+
+	// The per-user slot was not used up either
+	setPoints(10)
+	if _, err := RewardServiceInstance.Redeem(ctx, userID, reward.ID.Hex()); err != nil {
+		t.Errorf("Redeem with enough points: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultKeyRotationDays is the signing key lifetime when JWT_KEY_ROTATION_DAYS is unset
+const defaultKeyRotationDays = 30
+
+// maxKeyPublishLead is how long before its period a key is created and published
+const maxKeyPublishLead = 24 * time.Hour
+
+// keyRefreshInterval is how often running instances reload and rotate keys
+const keyRefreshInterval = time.Hour
+
+// SigningKeyService persists the JWT signing keys and rotates them
+// Time is split into rotation periods (JWT_KEY_ROTATION_DAYS, default 30) and
+// each period gets its own key, algorithm JWT_ALG (EdDSA or RS256, default EdDSA).
+// Keys live in MongoDB so every API instance signs with the same key; the unique
+// period index makes sure only one instance generates it. The private keys are
+// stored encrypted with SIGNING_KEY_SECRET (see utils.KeySealer). A retired key stays
+// available for verification until the longest-lived token it signed (a
+// refresh token) has expired.
+// Frontend integration: None directly; the public keys are served at
+// GET /.well-known/jwks.json
+type SigningKeyService struct {
+	collection *mongo.Collection // jwt_signing_keys collection
+	algorithm  string
+	rotation   time.Duration
+	sealer     *utils.KeySealer // Encrypts the private keys at rest
+}
+
+// NewSigningKeyService creates a new SigningKeyService instance
+// Reads JWT_ALG, JWT_KEY_ROTATION_DAYS and SIGNING_KEY_SECRET (required);
+// invalid values are an error
+func NewSigningKeyService(collection *mongo.Collection) (*SigningKeyService, error) {
+	sealer, err := utils.NewKeySealer(os.Getenv("SIGNING_KEY_SECRET"))
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := os.Getenv("JWT_ALG")
+	switch algorithm {
+	case "":
+		algorithm = utils.AlgEdDSA
+	case utils.AlgEdDSA, utils.AlgRS256:
+	default:
+		return nil, fmt.Errorf("JWT_ALG must be %s or %s, got %q", utils.AlgEdDSA, utils.AlgRS256, algorithm)
+	}
+
+	days := defaultKeyRotationDays
+	if value := os.Getenv("JWT_KEY_ROTATION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return nil, fmt.Errorf("JWT_KEY_ROTATION_DAYS must be a positive number of days, got %q", value)
+		}
+		days = parsed
+	}
+
+	return &SigningKeyService{
+		collection: collection,
+		algorithm:  algorithm,
+		rotation:   time.Duration(days) * 24 * time.Hour,
+		sealer:     sealer,
+	}, nil
+}
+
+// EnsureIndexes creates the signing key indexes
+//   - period: unique, one key per rotation period across all instances
+//   - expires_at: TTL, keys disappear once nothing they signed is valid
+//
+// Called once on startup from InitializeDB
+func (sks *SigningKeyService) EnsureIndexes(ctx context.Context) error {
+	_, err := sks.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "period", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Refresh makes sure the current (and, close to a rotation, the next) period
+// has a key, then loads all live keys into utils for signing and verification
+// Called on startup from InitializeDB and then every keyRefreshInterval
+func (sks *SigningKeyService) Refresh(ctx context.Context) error {
+	now := time.Now()
+	period := sks.periodAt(now)
+
+	if err := sks.ensureKey(ctx, period); err != nil {
+		return err
+	}
+	if sks.periodStart(period+1).Sub(now) <= sks.publishLead() {
+		if err := sks.ensureKey(ctx, period+1); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := sks.collection.Find(ctx, bson.M{"expires_at": bson.M{"$gt": now}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var records []model.SigningKeyRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return err
+	}
+
+	var signing *utils.SigningKey
+	verify := make([]*utils.SigningKey, 0, len(records))
+	for _, record := range records {
+		privatePEM, err := sks.privateKeyPEM(ctx, record)
+		if err != nil {
+			return err
+		}
+		key, err := utils.DecodeSigningKey(record.ID, record.Algorithm, privatePEM)
+		if err != nil {
+			return err
+		}
+		verify = append(verify, key)
+		if record.Period == period {
+			signing = key
+		}
+	}
+	if signing == nil {
+		return fmt.Errorf("no JWT signing key for period %d", period)
+	}
+
+	utils.SetSigningKeys(signing, verify)
+	return nil
+}
+
+// RunRotation refreshes the keys every keyRefreshInterval until ctx is done
+// Errors are logged; the previously loaded keys stay in use meanwhile
+func (sks *SigningKeyService) RunRotation(ctx context.Context) {
+	ticker := time.NewTicker(keyRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := sks.Refresh(refreshCtx); err != nil {
+				log.Println("JWT signing key refresh failed:", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// ensureKey generates the key for a period unless one exists already
+// Another instance winning the race (duplicate period) is not an error
+func (sks *SigningKeyService) ensureKey(ctx context.Context, period int64) error {
+	count, err := sks.collection.CountDocuments(ctx, bson.M{"period": period})
+	if err != nil || count > 0 {
+		return err
+	}
+
+	key, err := utils.GenerateSigningKey(sks.algorithm)
+	if err != nil {
+		return err
+	}
+	privatePEM, err := utils.EncodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	sealed, err := sks.sealer.Seal(key.ID, privatePEM)
+	if err != nil {
+		return err
+	}
+
+	activatesAt := sks.periodStart(period)
+	_, err = sks.collection.InsertOne(ctx, model.SigningKeyRecord{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  sealed,
+		Period:      period,
+		ActivatesAt: activatesAt,
+		CreatedAt:   time.Now(),
+		// Signs until the next period (plus one refresh interval of lag), then
+		// verifies until the last refresh token it signed has expired
+		ExpiresAt: activatesAt.Add(sks.rotation + keyRefreshInterval + RefreshTokenTTL),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// privateKeyPEM decrypts a stored private key
+// Keys written before encryption at rest are plain PEM; they are encrypted in
+// place on the way
+func (sks *SigningKeyService) privateKeyPEM(ctx context.Context, record model.SigningKeyRecord) (string, error) {
+	if utils.IsSealedKey(record.PrivateKey) {
+		return sks.sealer.Open(record.ID, record.PrivateKey)
+	}
+
+	sealed, err := sks.sealer.Seal(record.ID, record.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	_, err = sks.collection.UpdateOne(ctx,
+		bson.M{"_id": record.ID, "private_key": record.PrivateKey},
+		bson.M{"$set": bson.M{"private_key": sealed}},
+	)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("JWT signing key %s encrypted at rest", record.ID)
+	return record.PrivateKey, nil
+}
+
+// periodAt returns the rotation period containing t
+func (sks *SigningKeyService) periodAt(t time.Time) int64 {
+	return t.Unix() / int64(sks.rotation/time.Second)
+}
+
+// periodStart returns the time a rotation period begins
+func (sks *SigningKeyService) periodStart(period int64) time.Time {
+	return time.Unix(period*int64(sks.rotation/time.Second), 0)
+}
+
+// publishLead is how long before its period a key is created, at most half a period
+func (sks *SigningKeyService) publishLead() time.Duration {
+	if lead := sks.rotation / 2; lead < maxKeyPublishLead {
+		return lead
+	}
+	return maxKeyPublishLead
+}
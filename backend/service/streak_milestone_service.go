@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned by StreakMilestoneService so controllers can map them to HTTP statuses
+var (
+	ErrStreakMilestoneNotFound     = errors.New("streak milestone not found")
+	ErrStreakMilestoneExists       = errors.New("a milestone for this many days already exists")
+	ErrInvalidStreakMilestoneInput = errors.New("invalid streak milestone")
+)
+
+// defaultStreakMilestones are inserted on startup when no milestones are defined
+var defaultStreakMilestones = []model.StreakMilestoneInput{
+	{Days: 7, BonusPoints: 25, Title: "One week streak"},
+	{Days: 30, BonusPoints: 100, Title: "One month streak"},
+	{Days: 100, BonusPoints: 500, Title: "100 day streak"},
+}
+
+// StreakMilestoneService manages milestone definitions and pays milestone bonuses
+// Frontend integration: Called by streak_controller (check-in) and
+// streak_milestone_controller (admin)
+type StreakMilestoneService struct {
+	collection *mongo.Collection // streak_milestones collection
+	awards     *mongo.Collection // streak_milestone_awards collection
+}
+
+// NewStreakMilestoneService creates a new StreakMilestoneService instance
+func NewStreakMilestoneService(collection, awards *mongo.Collection) *StreakMilestoneService {
+	return &StreakMilestoneService{collection: collection, awards: awards}
+}
+
+// EnsureIndexes creates the unique indexes milestones rely on
+// - streak_milestones.days: one milestone per streak length
+// - streak_milestone_awards.(userId, streakStartDay, days): one bonus per milestone per streak
+// Called once on startup from InitializeDB
+func (ms *StreakMilestoneService) EnsureIndexes(ctx context.Context) error {
+	_, err := ms.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "days", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ms.awards.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "streakStartDay", Value: 1}, {Key: "days", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// SeedDefaults inserts the 7/30/100 day milestones when none are defined yet
+// Admins can change or remove them afterwards
+func (ms *StreakMilestoneService) SeedDefaults(ctx context.Context) error {
+	count, err := ms.collection.CountDocuments(ctx, bson.M{})
+	if err != nil || count > 0 {
+		return err
+	}
+
+	for _, input := range defaultStreakMilestones {
+		if _, err := ms.CreateMilestone(ctx, input); err != nil && !errors.Is(err, ErrStreakMilestoneExists) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListMilestones returns every milestone, shortest streak first
+func (ms *StreakMilestoneService) ListMilestones(ctx context.Context) ([]model.StreakMilestone, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "days", Value: 1}})
+	cursor, err := ms.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var milestones []model.StreakMilestone
+	if err = cursor.All(ctx, &milestones); err != nil {
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// GetMilestone retrieves a single milestone by ID
+func (ms *StreakMilestoneService) GetMilestone(ctx context.Context, milestoneID string) (*model.StreakMilestone, error) {
+	objID, err := primitive.ObjectIDFromHex(milestoneID)
+	if err != nil {
+		return nil, ErrStreakMilestoneNotFound
+	}
+
+	var milestone model.StreakMilestone
+	err = ms.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&milestone)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrStreakMilestoneNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &milestone, nil
+}
+
+// CreateMilestone adds a milestone (admin only)
+func (ms *StreakMilestoneService) CreateMilestone(ctx context.Context, input model.StreakMilestoneInput) (*model.StreakMilestone, error) {
+	if err := validateStreakMilestoneInput(input); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	milestone := &model.StreakMilestone{
+		ID:          primitive.NewObjectID(),
+		Days:        input.Days,
+		BonusPoints: input.BonusPoints,
+		Title:       input.Title,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := ms.collection.InsertOne(ctx, milestone); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrStreakMilestoneExists
+		}
+		return nil, err
+	}
+
+	return milestone, nil
+}
+
+// UpdateMilestone replaces a milestone's fields (admin only)
+// Bonuses already paid are not changed
+func (ms *StreakMilestoneService) UpdateMilestone(ctx context.Context, milestoneID string, input model.StreakMilestoneInput) (*model.StreakMilestone, error) {
+	if err := validateStreakMilestoneInput(input); err != nil {
+		return nil, err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(milestoneID)
+	if err != nil {
+		return nil, ErrStreakMilestoneNotFound
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"days":        input.Days,
+			"bonusPoints": input.BonusPoints,
+			"title":       input.Title,
+			"updatedAt":   time.Now(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var milestone model.StreakMilestone
+	err = ms.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, opts).Decode(&milestone)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrStreakMilestoneNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrStreakMilestoneExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &milestone, nil
+}
+
+// DeleteMilestone removes a milestone (admin only)
+func (ms *StreakMilestoneService) DeleteMilestone(ctx context.Context, milestoneID string) error {
+	objID, err := primitive.ObjectIDFromHex(milestoneID)
+	if err != nil {
+		return ErrStreakMilestoneNotFound
+	}
+
+	result, err := ms.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrStreakMilestoneNotFound
+	}
+
+	return nil
+}
+
+// AwardMilestones pays the bonus for every milestone the streak has reached
+// and not yet been paid for
+// Called after a successful check-in. A streak is identified by its start day,
+// so a repaired streak keeps the milestones it already collected while a new
+// streak can earn them all again. Milestones skipped over (e.g. by a repair)
+// are paid on the next check-in.
+// Returns the awards made by this call
+func (ms *StreakMilestoneService) AwardMilestones(ctx context.Context, userID string, streak *model.Streak) ([]model.StreakMilestoneAward, error) {
+	if streak.CurrentStreak == 0 || streak.StreakStartDay == "" {
+		return nil, nil
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	cursor, err := ms.collection.Find(ctx,
+		bson.M{"days": bson.M{"$lte": streak.CurrentStreak}},
+		options.Find().SetSort(bson.D{{Key: "days", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reached []model.StreakMilestone
+	if err = cursor.All(ctx, &reached); err != nil {
+		return nil, err
+	}
+
+	var awarded []model.StreakMilestoneAward
+	for _, milestone := range reached {
+		award := model.StreakMilestoneAward{
+			ID:             primitive.NewObjectID(),
+			UserID:         userObjID,
+			MilestoneID:    milestone.ID,
+			Days:           milestone.Days,
+			Title:          milestone.Title,
+			BonusPoints:    milestone.BonusPoints,
+			StreakStartDay: streak.StreakStartDay,
+			AwardedAt:      time.Now(),
+		}
+
+		// The unique index makes the insert the "award once" check
+		if _, err := ms.awards.InsertOne(ctx, award); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return awarded, err
+		}
+
+		_, err := LedgerServiceInstance.Award(ctx, userID, award.BonusPoints, model.PointsReasonStreakMilestone, award.ID.Hex())
+		if err != nil {
+			// Undo the award so the bonus is retried on the next check-in
+			_, _ = ms.awards.DeleteOne(ctx, bson.M{"_id": award.ID})
+			return awarded, err
+		}
+
+		awarded = append(awarded, award)
+	}
+
+	return awarded, nil
+}
+
+// validateStreakMilestoneInput checks admin-supplied milestone fields
+func validateStreakMilestoneInput(input model.StreakMilestoneInput) error {
+	if input.Days <= 0 {
+		return fmt.Errorf("%w: days must be positive", ErrInvalidStreakMilestoneInput)
+	}
+	if input.BonusPoints <= 0 {
+		return fmt.Errorf("%w: bonusPoints must be positive", ErrInvalidStreakMilestoneInput)
+	}
+	if input.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidStreakMilestoneInput)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"rewardpage/model"
 	"time"
@@ -12,20 +13,54 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Streak protection prices and limits
+const (
+	StreakFreezeCost       = 100 // Points per streak freeze item
+	StreakRepairCost       = 150 // Points to restore a broken streak
+	MaxStreakFreezes       = 3   // Freeze inventory cap (bought or earned)
+	StreakFreezeEarnedDays = 7   // One freeze is earned every 7 consecutive days
+	streakRepairWindow     = 48 * time.Hour
+)
+
+// Errors returned by the streak freeze and repair flows
+var (
+	ErrStreakFreezeLimit   = errors.New("streak freeze inventory is full")
+	ErrStreakNotBroken     = errors.New("there is no broken streak to repair")
+	ErrStreakRepairExpired = errors.New("streaks can only be repaired within 48 hours of breaking")
+)
+
 // StreakService handles user streak (daily check-in) data
 // Frontend integration: Called by streak_controller to manage weekly check-in grid
+// Keeps two views of the same data:
+// - streaks: one document per user (weekday grid + current/longest counters)
+// - streak_check_ins: append-only log, one entry per user per day
 type StreakService struct {
 	collection *mongo.Collection
+	checkIns   *mongo.Collection
 }
 
 // NewStreakService creates a new StreakService instance
-func NewStreakService(collection *mongo.Collection) *StreakService {
-	return &StreakService{collection: collection}
+func NewStreakService(collection, checkIns *mongo.Collection) *StreakService {
+	return &StreakService{collection: collection, checkIns: checkIns}
+}
+
+// EnsureIndexes creates the unique (user, day) index on the check-in log
+// The index is what guarantees a single check-in per user per day
+// Called once on startup from InitializeDB
+func (ss *StreakService) EnsureIndexes(ctx context.Context) error {
+	_, err := ss.checkIns.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "day", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
 }
 
 // GetStreakByUserID retrieves the streak record for a user
 // Used by frontend GET /api/streak endpoint to populate DailyStreak component
-// Returns: { mon, tue, wed, thu, fri, sat, sun } booleans
+// Returns: { mon, tue, wed, thu, fri, sat, sun } booleans plus streak counters
+// The weekday grid is cleared when a new week has started. Missed days are
+// covered by freezes when the user has enough of them; otherwise the current
+// streak drops to 0 and is kept as the broken streak for repair.
 func (ss *StreakService) GetStreakByUserID(ctx context.Context, userID string) (*model.Streak, error) {
 	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -44,109 +79,237 @@ func (ss *StreakService) GetStreakByUserID(ctx context.Context, userID string) (
 		return nil, err
 	}
 
+	now := time.Now().In(UserLocation(ctx, userID))
+	rolled := rollWeek(&streak, now)
+	broken, err := ss.settleMissedDays(ctx, &streak, now)
+	if err != nil {
+		return nil, err
+	}
+	if rolled || broken {
+		if err := ss.saveStreak(ctx, &streak); err != nil {
+			return nil, err
+		}
+	}
+
 	return &streak, nil
 }
 
 // UpdateStreak performs a daily check-in and marks the current day as completed
 // Called by frontend POST /api/streak/update endpoint
 // Logic:
-// 1. Get today's day of week (Mon-Sun)
-// 2. Update the corresponding field to true
-// 3. Update lastCheckIn timestamp
+//  1. Work out today's date and weekday in the user's timezone
+//  2. Insert today's entry into the check-in log (unique per user and day);
+//     if it already exists, return the streak unchanged with AlreadyCheckedIn
+//  3. Roll the week over if a new week started, then set today's weekday flag
+//  4. Extend the current streak if the user was active yesterday (checked in
+//     or covered by a freeze), else restart at 1
+//  5. Update longest streak and total check-ins
+//  6. Every StreakFreezeEarnedDays consecutive days, add a freeze to the inventory
+//
 // Returns the updated streak object
-func (ss *StreakService) UpdateStreak(ctx context.Context, userID string) (*model.Streak, error) {
+func (ss *StreakService) UpdateStreak(ctx context.Context, userID string) (*model.StreakCheckInResult, error) {
 	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID")
 	}
 
-	// Get current day name (Mon-Sun)
-	dayName := time.Now().Weekday().String()[:3]
-	dayLower := map[string]string{
-		"Mon": "mon",
-		"Tue": "tue",
-		"Wed": "wed",
-		"Thu": "thu",
-		"Fri": "fri",
-		"Sat": "sat",
-		"Sun": "sun",
-	}[dayName]
+	now := time.Now().In(UserLocation(ctx, userID))
+	today := now.Format(dayLayout)
 
-	filter := bson.M{"userId": userObjID}
+	entry := model.StreakCheckIn{
+		ID:          primitive.NewObjectID(),
+		UserID:      userObjID,
+		Day:         today,
+		Weekday:     weekdayKey(now.Weekday()),
+		CheckedInAt: now,
+	}
+	if _, err := ss.checkIns.InsertOne(ctx, entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			streak, err := ss.GetStreakByUserID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			return &model.StreakCheckInResult{Streak: streak, AlreadyCheckedIn: true}, nil
+		}
+		return nil, err
+	}
 
-	// Update the current day to true
-	update := bson.M{
-		"$set": bson.M{
-			dayLower:      true,
-			"lastCheckIn": time.Now(),
-			"updatedAt":   time.Now(),
-		},
+	streak, err := ss.GetStreakByUserID(ctx, userID)
+	if err != nil {
+		ss.removeCheckIn(ctx, entry.ID)
+		return nil, err
 	}
 
-	opts := options.FindOneAndUpdate()
-	opts.SetReturnDocument(options.After) // Return updated document
+	if lastActiveDay(streak) == addDays(today, -1) && streak.CurrentStreak > 0 {
+		streak.CurrentStreak++
+	} else {
+		streak.CurrentStreak = 1
+		streak.StreakStartDay = today
+	}
+	if streak.CurrentStreak > streak.LongestStreak {
+		streak.LongestStreak = streak.CurrentStreak
+	}
+	streak.TotalCheckIns++
+	streak.LastCheckInDay = today
+	streak.LastCheckIn = now
+	setWeekdayFlag(streak, entry.Weekday)
 
-	var updatedStreak model.Streak
-	err = ss.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedStreak)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			// If no record exists, create one and set today's day
-			newStreak := &model.Streak{
-				UserID:      userObjID,
-				LastCheckIn: time.Now(),
-				UpdatedAt:   time.Now(),
-			}
+	if err := ss.saveStreak(ctx, streak); err != nil {
+		// Let the user retry today's check-in
+		ss.removeCheckIn(ctx, entry.ID)
+		return nil, err
+	}
 
-			// Set current day to true
-			switch dayLower {
-			case "mon":
-				newStreak.Mon = true
-			case "tue":
-				newStreak.Tue = true
-			case "wed":
-				newStreak.Wed = true
-			case "thu":
-				newStreak.Thu = true
-			case "fri":
-				newStreak.Fri = true
-			case "sat":
-				newStreak.Sat = true
-			case "sun":
-				newStreak.Sun = true
-			}
+	result := &model.StreakCheckInResult{Streak: streak}
+	if streak.CurrentStreak%StreakFreezeEarnedDays == 0 {
+		earned, err := ss.addFreeze(ctx, streak.ID)
+		if err != nil {
+			return nil, err
+		}
+		if earned {
+			streak.Freezes++
+			result.FreezeEarned = true
+		}
+	}
 
-			insertedID, err := ss.collection.InsertOne(ctx, newStreak)
-			if err != nil {
-				return nil, err
-			}
+	return result, nil
+}
 
-			newStreak.ID = insertedID.InsertedID.(primitive.ObjectID)
-			return newStreak, nil
+// BuyFreeze spends StreakFreezeCost points on one streak freeze item
+// Called by frontend POST /api/streak/freezes endpoint
+// Errors: ErrInsufficientPoints, ErrStreakFreezeLimit when the inventory is full
+func (ss *StreakService) BuyFreeze(ctx context.Context, userID string) (*model.Streak, error) {
+	streak, err := ss.GetStreakByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if streak.Freezes >= MaxStreakFreezes {
+		return nil, ErrStreakFreezeLimit
+	}
+
+	purchaseID := primitive.NewObjectID().Hex()
+	if _, err := LedgerServiceInstance.Debit(ctx, userID, StreakFreezeCost, model.PointsReasonStreakFreeze, purchaseID); err != nil {
+		return nil, err
+	}
+
+	added, err := ss.addFreeze(ctx, streak.ID)
+	if err != nil || !added {
+		// Inventory filled up concurrently (or the write failed); give the points back
+		_, _ = LedgerServiceInstance.Record(ctx, userID, StreakFreezeCost, model.PointsReasonStreakRefund, purchaseID)
+		if err != nil {
+			return nil, err
 		}
+		return nil, ErrStreakFreezeLimit
+	}
+
+	streak.Freezes++
+	return streak, nil
+}
+
+// RepairStreak restores the last broken streak for StreakRepairCost points
+// Called by frontend POST /api/streak/repair endpoint
+// Only allowed within 48 hours of the streak breaking (the end of the first
+// missed day). Days checked in since the break are added on top, and the gap
+// is treated as covered so the streak continues from today.
+// Errors: ErrStreakNotBroken, ErrStreakRepairExpired, ErrInsufficientPoints
+func (ss *StreakService) RepairStreak(ctx context.Context, userID string) (*model.Streak, error) {
+	streak, err := ss.GetStreakByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if streak.BrokenStreak == 0 {
+		return nil, ErrStreakNotBroken
+	}
+
+	loc := UserLocation(ctx, userID)
+	now := time.Now().In(loc)
+	brokenAt, err := time.ParseInLocation(dayLayout, addDays(streak.BrokenAfterDay, 2), loc)
+	if err != nil {
+		return nil, ErrStreakNotBroken
+	}
+	if now.Sub(brokenAt) > streakRepairWindow {
+		return nil, ErrStreakRepairExpired
+	}
+
+	// One repair per break: the source ties the charge to this particular gap
+	sourceID := streak.ID.Hex() + ":" + streak.BrokenAfterDay
+	if _, err := LedgerServiceInstance.Debit(ctx, userID, StreakRepairCost, model.PointsReasonStreakRepair, sourceID); err != nil {
 		return nil, err
 	}
 
-	return &updatedStreak, nil
+	restored := streak.BrokenStreak + streak.CurrentStreak
+	yesterday := addDays(now.Format(dayLayout), -1)
+	longest := streak.LongestStreak
+	if restored > longest {
+		longest = restored
+	}
+
+	// Conditional on the break still being there, so a double submit only repairs once
+	result, err := ss.collection.UpdateOne(ctx,
+		bson.M{"_id": streak.ID, "brokenAfterDay": streak.BrokenAfterDay, "brokenStreak": streak.BrokenStreak},
+		bson.M{
+			"$set": bson.M{
+				"currentStreak":    restored,
+				"longestStreak":    longest,
+				"streakStartDay":   streak.BrokenStreakStartDay,
+				"frozenThroughDay": maxDay(streak.FrozenThroughDay, yesterday),
+				"updatedAt":        time.Now(),
+			},
+			"$unset": bson.M{"brokenStreak": "", "brokenStreakStartDay": "", "brokenAfterDay": ""},
+		},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		_, _ = LedgerServiceInstance.Record(ctx, userID, StreakRepairCost, model.PointsReasonStreakRefund, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrStreakNotBroken
+	}
+
+	return ss.GetStreakByUserID(ctx, userID)
 }
 
-// ResetStreakDaily resets all user streaks at midnight (called via cron job)
-// Typically run at 11:59 PM daily to reset the next day's check-in
-// This allows users to check in again on the new day
+// ResetStreakDaily settles streaks that have missed a day
+// Could be run via cron job; reads already apply the same rule lazily, so this
+// only keeps stored counters tidy for reporting.
+// A streak is a candidate once its last check-in is more than 48 hours old,
+// which is past "yesterday" in every timezone. Each candidate goes through the
+// same freeze/break logic as a read.
 func (ss *StreakService) ResetStreakDaily(ctx context.Context) error {
-	// Optional: Only reset if user checked in today
-	// This preserves the streak chain and motivation
+	cutoff := time.Now().Add(-48 * time.Hour)
+
+	cursor, err := ss.collection.Find(ctx,
+		bson.M{"currentStreak": bson.M{"$gt": 0}, "lastCheckIn": bson.M{"$lt": cutoff}},
+		options.Find().SetProjection(bson.M{"userId": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var stale []model.Streak
+	if err := cursor.All(ctx, &stale); err != nil {
+		return err
+	}
+
+	for _, streak := range stale {
+		if _, err := ss.GetStreakByUserID(ctx, streak.UserID.Hex()); err != nil {
+			return err
+		}
+	}
 
-	// For now, we don't auto-reset; users can check in fresh each day
 	return nil
 }
 
 // CreateStreakRecord initializes a new streak record for a user
 func (ss *StreakService) CreateStreakRecord(ctx context.Context, userObjID primitive.ObjectID) (*model.Streak, error) {
+	now := time.Now().In(UserLocation(ctx, userObjID.Hex()))
 	streak := &model.Streak{
 		ID:          primitive.NewObjectID(),
 		UserID:      userObjID,
-		LastCheckIn: time.Now(),
-		UpdatedAt:   time.Now(),
+		LastCheckIn: now,
+		UpdatedAt:   now,
+		WeekStart:   weekStartDay(now),
 	}
 
 	_, err := ss.collection.InsertOne(ctx, streak)
@@ -165,28 +328,220 @@ func (ss *StreakService) GetStreakCount(ctx context.Context, userID string) (int
 		return 0, err
 	}
 
-	count := 0
-	if streak.Mon {
-		count++
+	return streak.CurrentStreak, nil
+}
+
+// GetStreakStats returns current and longest streak plus total check-ins
+// Used by frontend GET /api/streak/stats endpoint
+func (ss *StreakService) GetStreakStats(ctx context.Context, userID string) (*model.StreakStats, error) {
+	streak, err := ss.GetStreakByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.StreakStats{
+		CurrentStreak:  streak.CurrentStreak,
+		LongestStreak:  streak.LongestStreak,
+		TotalCheckIns:  streak.TotalCheckIns,
+		LastCheckInDay: streak.LastCheckInDay,
+	}, nil
+}
+
+// saveStreak writes the tracked fields of the streak document
+// Freeze counters and frozenThroughDay are left out: they only change through
+// the conditional updates in addFreeze, useFreezes and RepairStreak.
+func (ss *StreakService) saveStreak(ctx context.Context, streak *model.Streak) error {
+	streak.UpdatedAt = time.Now()
+
+	_, err := ss.collection.UpdateOne(ctx, bson.M{"_id": streak.ID}, bson.M{
+		"$set": bson.M{
+			"mon":            streak.Mon,
+			"tue":            streak.Tue,
+			"wed":            streak.Wed,
+			"thu":            streak.Thu,
+			"fri":            streak.Fri,
+			"sat":            streak.Sat,
+			"sun":            streak.Sun,
+			"lastCheckIn":    streak.LastCheckIn,
+			"updatedAt":      streak.UpdatedAt,
+			"currentStreak":  streak.CurrentStreak,
+			"longestStreak":  streak.LongestStreak,
+			"totalCheckIns":  streak.TotalCheckIns,
+			"lastCheckInDay": streak.LastCheckInDay,
+			"streakStartDay": streak.StreakStartDay,
+			"weekStart":      streak.WeekStart,
+			// Broken run kept for repair
+			"brokenStreak":         streak.BrokenStreak,
+			"brokenStreakStartDay": streak.BrokenStreakStartDay,
+			"brokenAfterDay":       streak.BrokenAfterDay,
+		},
+	})
+	return err
+}
+
+// addFreeze adds one freeze to the inventory unless it is already full
+// Returns false when the cap was reached
+func (ss *StreakService) addFreeze(ctx context.Context, streakID primitive.ObjectID) (bool, error) {
+	result, err := ss.collection.UpdateOne(ctx,
+		bson.M{"_id": streakID, "$or": bson.A{
+			bson.M{"freezes": bson.M{"$lt": MaxStreakFreezes}},
+			bson.M{"freezes": bson.M{"$exists": false}},
+		}},
+		bson.M{"$inc": bson.M{"freezes": 1}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// settleMissedDays handles the days missed since the streak was last active
+// Freezes cover the whole gap automatically when the user has enough of them
+// (a gap is never partly covered). Otherwise the streak breaks: CurrentStreak
+// drops to 0 and the run is remembered so RepairStreak can restore it.
+// Returns true when the streak broke and should be saved
+func (ss *StreakService) settleMissedDays(ctx context.Context, streak *model.Streak, now time.Time) (bool, error) {
+	yesterday := addDays(now.Format(dayLayout), -1)
+	last := lastActiveDay(streak)
+	if streak.CurrentStreak == 0 || last >= yesterday {
+		return false, nil
+	}
+
+	missed := daysBetween(last, yesterday)
+	if missed <= streak.Freezes {
+		covered, err := ss.useFreezes(ctx, streak, missed, yesterday)
+		if err != nil {
+			return false, err
+		}
+		if covered {
+			return false, nil
+		}
 	}
-	if streak.Tue {
-		count++
+
+	streak.BrokenStreak = streak.CurrentStreak
+	streak.BrokenStreakStartDay = streak.StreakStartDay
+	streak.BrokenAfterDay = last
+	streak.CurrentStreak = 0
+	return true, nil
+}
+
+// useFreezes consumes freezes to cover the missed days up to through
+// Conditional on the stored inventory and frozenThroughDay, so concurrent reads
+// of the same gap only consume once. Returns false if the gap stays uncovered.
+func (ss *StreakService) useFreezes(ctx context.Context, streak *model.Streak, missed int, through string) (bool, error) {
+	var frozenThrough interface{} = streak.FrozenThroughDay
+	if streak.FrozenThroughDay == "" {
+		frozenThrough = bson.M{"$in": bson.A{"", nil}}
 	}
-	if streak.Wed {
-		count++
+
+	result, err := ss.collection.UpdateOne(ctx,
+		bson.M{"_id": streak.ID, "freezes": bson.M{"$gte": missed}, "frozenThroughDay": frozenThrough},
+		bson.M{
+			"$inc": bson.M{"freezes": -missed, "freezesUsed": missed},
+			"$set": bson.M{"frozenThroughDay": through, "updatedAt": time.Now()},
+		},
+	)
+	if err != nil {
+		return false, err
 	}
-	if streak.Thu {
-		count++
+	if result.ModifiedCount == 1 {
+		streak.Freezes -= missed
+		streak.FreezesUsed += missed
+		streak.FrozenThroughDay = through
+		return true, nil
 	}
-	if streak.Fri {
-		count++
+
+	// Another request may have covered the gap first; use its result
+	var current model.Streak
+	if err := ss.collection.FindOne(ctx, bson.M{"_id": streak.ID}).Decode(&current); err != nil {
+		return false, err
 	}
-	if streak.Sat {
-		count++
+	if current.FrozenThroughDay >= through {
+		streak.Freezes = current.Freezes
+		streak.FreezesUsed = current.FreezesUsed
+		streak.FrozenThroughDay = current.FrozenThroughDay
+		return true, nil
 	}
-	if streak.Sun {
-		count++
+
+	return false, nil
+}
+
+// removeCheckIn deletes a check-in log entry after a failed streak update
+func (ss *StreakService) removeCheckIn(ctx context.Context, id primitive.ObjectID) {
+	_, _ = ss.checkIns.DeleteOne(ctx, bson.M{"_id": id})
+}
+
+// rollWeek clears the weekday grid when now falls in a later week than WeekStart
+// Returns true when the streak changed and should be saved
+func rollWeek(streak *model.Streak, now time.Time) bool {
+	changed := false
+
+	// Records from before week tracking belong to the week of their last check-in
+	if streak.WeekStart == "" && !streak.LastCheckIn.IsZero() {
+		streak.WeekStart = weekStartDay(streak.LastCheckIn.In(now.Location()))
+		changed = true
 	}
 
-	return count, nil
+	weekStart := weekStartDay(now)
+	if streak.WeekStart != weekStart {
+		streak.Mon, streak.Tue, streak.Wed, streak.Thu = false, false, false, false
+		streak.Fri, streak.Sat, streak.Sun = false, false, false
+		streak.WeekStart = weekStart
+		changed = true
+	}
+
+	return changed
+}
+
+// lastActiveDay is the later of the last check-in and the last frozen day
+func lastActiveDay(streak *model.Streak) string {
+	return maxDay(streak.LastCheckInDay, streak.FrozenThroughDay)
+}
+
+// maxDay returns the later of two "2006-01-02" day keys
+// Day keys sort lexically, and an empty key sorts first
+func maxDay(a, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// setWeekdayFlag marks one weekday of the grid as checked in
+func setWeekdayFlag(streak *model.Streak, day string) {
+	switch day {
+	case "mon":
+		streak.Mon = true
+	case "tue":
+		streak.Tue = true
+	case "wed":
+		streak.Wed = true
+	case "thu":
+		streak.Thu = true
+	case "fri":
+		streak.Fri = true
+	case "sat":
+		streak.Sat = true
+	case "sun":
+		streak.Sun = true
+	}
+}
+
+// addDays shifts a "2006-01-02" day key by n calendar days
+func addDays(day string, n int) string {
+	t, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, n).Format(dayLayout)
+}
+
+// daysBetween counts the calendar days from one day key to another
+func daysBetween(from, to string) int {
+	f, err1 := time.Parse(dayLayout, from)
+	t, err2 := time.Parse(dayLayout, to)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return int(t.Sub(f).Hours() / 24)
 }
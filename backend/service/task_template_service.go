@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rewardpage/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTaskPoints is the value of a daily task when no template sets one
+// Also used for the fallback checklist when no template is active
+const DefaultTaskPoints = 20
+
+// DefaultTaskCount is the size of the fallback checklist when no template is active
+const DefaultTaskCount = 5
+
+// Errors returned by TaskTemplateService so controllers can map them to HTTP statuses
+var (
+	ErrTaskTemplateNotFound     = errors.New("task template not found")
+	ErrInvalidTaskTemplateInput = errors.New("invalid task template")
+)
+
+// validTaskBoxes are the task categories from model.Task
+var validTaskBoxes = map[string]bool{"left": true, "right": true, "center": true}
+
+// TaskTemplateService manages the definitions daily tasks are generated from
+// Frontend integration: Called by task_template_controller (admin) and DailyTaskService
+type TaskTemplateService struct {
+	collection *mongo.Collection // task_templates collection
+}
+
+// NewTaskTemplateService creates a new TaskTemplateService instance
+func NewTaskTemplateService(collection *mongo.Collection) *TaskTemplateService {
+	return &TaskTemplateService{collection: collection}
+}
+
+// ListTemplates returns every template in display order (admin view)
+func (ts *TaskTemplateService) ListTemplates(ctx context.Context) ([]model.TaskTemplate, error) {
+	return ts.find(ctx, bson.M{})
+}
+
+// GetTemplate retrieves a single template by ID
+func (ts *TaskTemplateService) GetTemplate(ctx context.Context, templateID string) (*model.TaskTemplate, error) {
+	objID, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		return nil, ErrTaskTemplateNotFound
+	}
+
+	var template model.TaskTemplate
+	err = ts.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTaskTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// ListActive returns the templates whose active date range contains at
+// Used by DailyTaskService when generating a user's checklist for the day
+func (ts *TaskTemplateService) ListActive(ctx context.Context, at time.Time) ([]model.TaskTemplate, error) {
+	filter := bson.M{
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"active_from": nil}, bson.M{"active_from": bson.M{"$lte": at}}}},
+			bson.M{"$or": bson.A{bson.M{"active_until": nil}, bson.M{"active_until": bson.M{"$gt": at}}}},
+		},
+	}
+	return ts.find(ctx, filter)
+}
+
+// CreateTemplate adds a new task template (admin only)
+func (ts *TaskTemplateService) CreateTemplate(ctx context.Context, input model.TaskTemplateInput) (*model.TaskTemplate, error) {
+	if err := validateTaskTemplateInput(input); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &model.TaskTemplate{
+		ID:          primitive.NewObjectID(),
+		Title:       input.Title,
+		Description: input.Description,
+		Points:      input.Points,
+		Box:         input.Box,
+		ActiveFrom:  input.ActiveFrom,
+		ActiveUntil: input.ActiveUntil,
+		SortOrder:   input.SortOrder,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := ts.collection.InsertOne(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// UpdateTemplate replaces a template's editable fields (admin only)
+// Tasks already generated for today keep their copied values
+func (ts *TaskTemplateService) UpdateTemplate(ctx context.Context, templateID string, input model.TaskTemplateInput) (*model.TaskTemplate, error) {
+	if err := validateTaskTemplateInput(input); err != nil {
+		return nil, err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		return nil, ErrTaskTemplateNotFound
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"title":        input.Title,
+			"description":  input.Description,
+			"points":       input.Points,
+			"box":          input.Box,
+			"active_from":  input.ActiveFrom,
+			"active_until": input.ActiveUntil,
+			"sort_order":   input.SortOrder,
+			"updated_at":   time.Now(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var template model.TaskTemplate
+	err = ts.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, opts).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTaskTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// DeleteTemplate removes a task template (admin only)
+func (ts *TaskTemplateService) DeleteTemplate(ctx context.Context, templateID string) error {
+	objID, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		return ErrTaskTemplateNotFound
+	}
+
+	result, err := ts.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrTaskTemplateNotFound
+	}
+
+	return nil
+}
+
+// find runs a template query in display order
+func (ts *TaskTemplateService) find(ctx context.Context, filter bson.M) ([]model.TaskTemplate, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sort_order", Value: 1}, {Key: "created_at", Value: 1}})
+	cursor, err := ts.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var templates []model.TaskTemplate
+	if err = cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// validateTaskTemplateInput checks admin-supplied template fields
+func validateTaskTemplateInput(input model.TaskTemplateInput) error {
+	if input.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidTaskTemplateInput)
+	}
+	if input.Points <= 0 {
+		return fmt.Errorf("%w: points must be positive", ErrInvalidTaskTemplateInput)
+	}
+	if !validTaskBoxes[input.Box] {
+		return fmt.Errorf("%w: box must be left, right or center", ErrInvalidTaskTemplateInput)
+	}
+	if input.ActiveFrom != nil && input.ActiveUntil != nil && !input.ActiveUntil.After(*input.ActiveFrom) {
+		return fmt.Errorf("%w: activeUntil must be after activeFrom", ErrInvalidTaskTemplateInput)
+	}
+	return nil
+}
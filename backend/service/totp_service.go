@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets when enabling 2FA
+const recoveryCodeCount = 10
+
+// Errors returned by TOTPService so controllers can map them to HTTP statuses
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled    = errors.New("start two-factor enrollment first")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode     = errors.New("authentication code is invalid")
+)
+
+// TOTPService manages authenticator app (TOTP) two-factor authentication
+// Enrollment is two steps: Enroll creates a secret the user adds to their app,
+// ConfirmEnrollment turns 2FA on once the app produces a valid code and hands
+// out the recovery codes. From then on Login asks for a code after the password.
+// Frontend integration: /api/users/mfa endpoints and POST /api/users/login/mfa
+type TOTPService struct {
+	collection *mongo.Collection // totp_credentials collection
+	sealer     *utils.KeySealer  // Encrypts the secrets at rest
+}
+
+// NewTOTPService creates a new TOTPService instance
+func NewTOTPService(collection *mongo.Collection, sealer *utils.KeySealer) *TOTPService {
+	return &TOTPService{collection: collection, sealer: sealer}
+}
+
+// Enroll starts (or restarts) enrollment with a new secret
+// Returns the base32 secret and the otpauth:// URI for a QR code; 2FA stays
+// off until ConfirmEnrollment. Returns ErrTOTPAlreadyEnabled when it is on.
+func (ts *TOTPService) Enroll(ctx context.Context, userID, email string) (string, string, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := ts.sealer.Seal(totpSealID(userID), secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	// An enabled credential does not match the filter, so the upsert collides with its _id
+	_, err = ts.collection.ReplaceOne(ctx,
+		bson.M{"_id": userID, "enabled": false},
+		model.TOTPCredential{UserID: userID, Secret: sealed, CreatedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, utils.TOTPProvisioningURI(secret, email), nil
+}
+
+// ConfirmEnrollment turns 2FA on once code matches the pending secret
+// Returns the recovery codes in plain text; they are never shown again
+func (ts *TOTPService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	credential, err := ts.find(ctx, userID)
+	if errors.Is(err, ErrTOTPNotEnabled) {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if credential.Enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	secret, err := ts.openSecret(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashed, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := ts.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "secret": credential.Secret, "enabled": false},
+		bson.M{"$set": bson.M{"enabled": true, "enabled_at": now, "last_step": step, "recovery_codes": hashed}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		// Enrollment was restarted or confirmed by a concurrent request
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	return codes, nil
+}
+
+// IsEnabled reports whether Login must ask the user for a second factor
+func (ts *TOTPService) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	count, err := ts.collection.CountDocuments(ctx, bson.M{"_id": userID, "enabled": true})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Status returns whether 2FA is on and how many recovery codes are unused
+func (ts *TOTPService) Status(ctx context.Context, userID string) (bool, int, error) {
+	credential, err := ts.find(ctx, userID)
+	if errors.Is(err, ErrTOTPNotEnabled) {
+		return false, 0, nil
+	}
+	if err != nil || !credential.Enabled {
+		return false, 0, err
+	}
+
+	remaining := 0
+	for _, code := range credential.RecoveryCodes {
+		if code.UsedAt == nil {
+			remaining++
+		}
+	}
+	return true, remaining, nil
+}
+
+// Verify checks a code from the user's authenticator app
+// Each code works once: a time step at or before the last accepted one is
+// rejected. Returns ErrInvalidMFACode for wrong or replayed codes.
+func (ts *TOTPService) Verify(ctx context.Context, userID, code string) error {
+	credential, err := ts.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !credential.Enabled {
+		return ErrTOTPNotEnabled
+	}
+	secret, err := ts.openSecret(ctx, credential)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= credential.LastStep {
+		return ErrInvalidMFACode
+	}
+
+	result, err := ts.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": true, "last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"last_step": step}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseRecoveryCode spends one of the user's recovery codes
+// Dashes, spaces and case are ignored. Returns ErrInvalidMFACode for unknown
+// or already used codes.
+func (ts *TOTPService) UseRecoveryCode(ctx context.Context, userID, code string) error {
+	result, err := ts.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "enabled": true, "recovery_codes": bson.M{"$elemMatch": bson.M{
+			"hash":    hashSecretToken(normalizeRecoveryCode(code)),
+			"used_at": nil,
+		}}},
+		bson.M{"$set": bson.M{"recovery_codes.$.used_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// Disable turns 2FA off and forgets the secret and recovery codes
+func (ts *TOTPService) Disable(ctx context.Context, userID string) error {
+	result, err := ts.collection.DeleteOne(ctx, bson.M{"_id": userID, "enabled": true})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrTOTPNotEnabled
+	}
+	return nil
+}
+
+// find loads the user's credential; ErrTOTPNotEnabled when there is none
+func (ts *TOTPService) find(ctx context.Context, userID string) (*model.TOTPCredential, error) {
+	var credential model.TOTPCredential
+	err := ts.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&credential)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// openSecret decrypts the credential's secret
+// Secrets stored before encryption at rest are plain base32; they are
+// encrypted in place on the way, and credential.Secret is updated to match
+func (ts *TOTPService) openSecret(ctx context.Context, credential *model.TOTPCredential) (string, error) {
+	if utils.IsSealedKey(credential.Secret) {
+		return ts.sealer.Open(totpSealID(credential.UserID), credential.Secret)
+	}
+
+	secret := credential.Secret
+	sealed, err := ts.sealer.Seal(totpSealID(credential.UserID), secret)
+	if err != nil {
+		return "", err
+	}
+	result, err := ts.collection.UpdateOne(ctx,
+		bson.M{"_id": credential.UserID, "secret": secret},
+		bson.M{"$set": bson.M{"secret": sealed}},
+	)
+	if err != nil {
+		return "", err
+	}
+	if result.MatchedCount == 1 {
+		credential.Secret = sealed
+	}
+	return secret, nil
+}
+
+// SealPlainSecrets encrypts the secrets stored before encryption at rest
+// Run from runMigrations on startup; secrets an older instance still writes
+// meanwhile are sealed on their next use (openSecret).
+func (ts *TOTPService) SealPlainSecrets(ctx context.Context) error {
+	cursor, err := ts.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var credential model.TOTPCredential
+		if err := cursor.Decode(&credential); err != nil {
+			return err
+		}
+		if utils.IsSealedKey(credential.Secret) {
+			continue
+		}
+		if _, err := ts.openSecret(ctx, &credential); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// totpSealID is the ID a user's secret is sealed under, so it only opens for that user
+func totpSealID(userID string) string {
+	return "totp:" + userID
+}
+
+// newRecoveryCodes returns recoveryCodeCount codes ("xxxxx-xxxxx") and their hashes
+func newRecoveryCodes() ([]string, []model.RecoveryCode, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashed := make([]model.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashed[i] = model.RecoveryCode{Hash: hashSecretToken(code)}
+	}
+	return codes, hashed, nil
+}
+
+// normalizeRecoveryCode strips the formatting users may type along with a code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"rewardpage/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newTestTOTPService returns a TOTPService on db with a fixed sealing secret
+func newTestTOTPService(t *testing.T, db *mongo.Database) (*TOTPService, *utils.KeySealer) {
+	t.Helper()
+
+	sealer, err := utils.NewKeySealer(strings.Repeat("s", 32))
+	if err != nil {
+		t.Fatalf("NewKeySealer: %v", err)
+	}
+	return NewTOTPService(db.Collection(totpCredentialsColName), sealer), sealer
+}
+
+// storedSecret reads the secret field as stored in MongoDB
+func storedSecret(t *testing.T, db *mongo.Database, userID string) string {
+	t.Helper()
+
+	var credential model.TOTPCredential
+	if err := db.Collection(totpCredentialsColName).FindOne(context.Background(), bson.M{"_id": userID}).Decode(&credential); err != nil {
+		t.Fatalf("find credential: %v", err)
+	}
+	return credential.Secret
+}
+
+func TestEnrollStoresSealedSecret(t *testing.T) {
+	db := newTestDB(t)
+	ts, sealer := newTestTOTPService(t, db)
+
+	secret, _, err := ts.Enroll(context.Background(), "user", "user@example.com")
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+
+	stored := storedSecret(t, db, "user")
+	if !utils.IsSealedKey(stored) || strings.Contains(stored, secret) {
+		t.Fatalf("secret stored in plain text: %q", stored)
+	}
+	opened, err := sealer.Open(totpSealID("user"), stored)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if opened != secret {
+		t.Error("stored secret differs from the enrolled one")
+	}
+}
+
+func TestPlainSecretIsSealedOnUse(t *testing.T) {
+	db := newTestDB(t)
+	ts, sealer := newTestTOTPService(t, db)
+	ctx := context.Background()
+
+	// A pending enrollment written before secrets were encrypted
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	_, err = db.Collection(totpCredentialsColName).InsertOne(ctx, model.TOTPCredential{UserID: "user", Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("insert credential: %v", err)
+	}
+
+	// "------" is never a valid code, so only the sealing is observed
+	if _, err := ts.ConfirmEnrollment(ctx, "user", "------"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("ConfirmEnrollment error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	opened, err := sealer.Open(totpSealID("user"), storedSecret(t, db, "user"))
+	if err != nil {
+		t.Fatalf("stored secret was not sealed: %v", err)
+	}
+	if opened != secret {
+		t.Error("sealed secret differs from the original")
+	}
+}
+
+func TestSpendTokenOnlyOnce(t *testing.T) {
+	db := newTestDB(t)
+	bs := NewBlacklistService(db.Collection(blacklistColName))
+	expiresAt := time.Now().Add(time.Minute)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		spent int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := bs.SpendToken(context.Background(), "challenge", "user", expiresAt)
+			if err != nil {
+				t.Errorf("SpendToken: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				spent++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if spent != 1 {
+		t.Errorf("token spent %d times, want 1", spent)
+	}
+}
+
+func TestSealPlainSecrets(t *testing.T) {
+	db := newTestDB(t)
+	ts, sealer := newTestTOTPService(t, db)
+	ctx := context.Background()
+
+	if _, _, err := ts.Enroll(ctx, "sealed", "sealed@example.com"); err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	sealedBefore := storedSecret(t, db, "sealed")
+	_, err := db.Collection(totpCredentialsColName).InsertOne(ctx, model.TOTPCredential{UserID: "plain", Secret: "JBSWY3DPEHPK3PXP", Enabled: true, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("insert credential: %v", err)
+	}
+
+	if err := ts.SealPlainSecrets(ctx); err != nil {
+		t.Fatalf("SealPlainSecrets: %v", err)
+	}
+
+	if opened, err := sealer.Open(totpSealID("plain"), storedSecret(t, db, "plain")); err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("plain secret after migration opens to %q, %v", opened, err)
+	}
+	if storedSecret(t, db, "sealed") != sealedBefore {
+		t.Error("an already sealed secret was rewritten")
+	}
+}
@@ -2,21 +2,79 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
 	"rewardpage/model"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type UserService struct {
-	collection *mongo.Collection
+// Errors returned by UserService so controllers can map them to HTTP statuses
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailExists      = errors.New("email already exists")
+	ErrAccountSuspended = errors.New("account is suspended")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrSelfModification = errors.New("admins cannot change their own role or suspension")
+	ErrWeakPassword     = errors.New("password must be at least 8 characters")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrPasswordNotSet   = errors.New("this account has no password yet; set one through the forgot password link first")
+	ErrEmailNotVerified = errors.New("please verify your email address first")
+	ErrInvalidProfile   = errors.New("invalid profile")
+	// Wrapped in a *TimezoneCooldownError telling how long to wait
+	ErrTimezoneChangeTooSoon = errors.New("the timezone was changed recently; please wait before changing it again")
+)
+
+// timezoneChangeCooldown is the minimum time between two timezone changes
+// Each change can move the start of the user's day, so without a limit hopping
+// across timezones would repeat check-ins and daily tasks within one real day
+const timezoneChangeCooldown = 7 * 24 * time.Hour
+
+// TimezoneCooldownError is returned while a timezone change is not allowed yet
+// errors.Is(err, ErrTimezoneChangeTooSoon) matches it
+type TimezoneCooldownError struct {
+	Remaining time.Duration
+}
+
+func (e *TimezoneCooldownError) Error() string {
+	return ErrTimezoneChangeTooSoon.Error()
+}
+
+func (e *TimezoneCooldownError) Unwrap() error {
+	return ErrTimezoneChangeTooSoon
+}
+
+// RemainingSeconds rounds the wait up to whole seconds
+func (e *TimezoneCooldownError) RemainingSeconds() int {
+	return int((e.Remaining + time.Second - 1) / time.Second)
 }
 
-// Added BlacklistService for token blacklisting
-type BlacklistService struct {
+// minPasswordLength is enforced when a password is reset or changed
+const minPasswordLength = 8
+
+// Limits for the fields users set through PATCH /api/users/me
+const (
+	minUsernameLength    = 3
+	maxUsernameLength    = 32
+	maxDisplayNameLength = 64
+	maxAvatarURLLength   = 2048
+)
+
+// validRoles lists the roles an admin can assign
+var validRoles = map[string]bool{model.RoleUser: true, model.RoleAdmin: true}
+
+type UserService struct {
 	collection *mongo.Collection
 }
 
@@ -24,105 +82,528 @@ func NewUserService(collection *mongo.Collection) *UserService {
 	return &UserService{collection: collection}
 }
 
-// Added NewBlacklistService for initializing blacklist service
-func NewBlacklistService(collection *mongo.Collection) *BlacklistService {
-	return &BlacklistService{collection: collection}
+// EnsureIndexes creates the users indexes
+//   - normalized_email: unique, one account per mailbox (aliases included);
+//     sparse because accounts sharing a mailbox from before keep it only on
+//     the oldest (see BackfillNormalizedEmails)
+//
+// Called on startup from InitializeDB, after the migrations
+func (us *UserService) EnsureIndexes(ctx context.Context) error {
+	_, err := us.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "normalized_email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	return err
+}
+
+// emailTakenFilter matches accounts using email or another alias of its mailbox
+func emailTakenFilter(email string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"email": email},
+		bson.M{"normalized_email": NormalizeEmail(email)},
+	}}
 }
 
 // CreateUser creates a new user with password hashing and duplicate email check
-func (us *UserService) CreateUser(ctx context.Context, user model.UserInput) error {
+// An alias of a registered mailbox (e.g. name+tag@) counts as a duplicate.
+// Every new user gets their own referral code
+// Returns the stored user so callers can attribute a referral to it
+func (us *UserService) CreateUser(ctx context.Context, input model.UserInput) (*model.User, error) {
 	// Check if email already exists
-	count, err := us.collection.CountDocuments(ctx, bson.M{"email": user.Email})
+	count, err := us.collection.CountDocuments(ctx, emailTakenFilter(input.Email))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if count > 0 {
-		return fmt.Errorf("email already exists")
+		return nil, ErrEmailExists
+	}
+
+	// Validate the optional timezone before creating anything
+	if _, err := LoadTimezone(input.Timezone); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	user.Password = string(hashedPassword)
-
-	// Set default role if not provided
-	// Added for role-based authorization
-	if user.Role == "" {
-		user.Role = "user"
+	user := &model.User{
+		ID:              primitive.NewObjectID(),
+		Username:        input.Username,
+		Email:           input.Email,
+		Password:        string(hashedPassword),
+		Role:            model.RoleUser, // Roles are only granted through the admin API
+		Points:          0,
+		NormalizedEmail: NormalizeEmail(input.Email),
+		Timezone:        input.Timezone,
 	}
-
-	_, err = us.collection.InsertOne(ctx, user)
-	if err != nil {
-		return err
+	if err := us.insertUser(ctx, user); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return user, nil
 }
 
-// GetAllUsers retrieves all users from the database
-func (us *UserService) GetAllUsers(ctx context.Context) ([]bson.M, error) {
-	cursor, err := us.collection.Find(ctx, bson.D{})
+// CreateExternalUser creates a user who signed in through an OIDC provider
+// The provider has verified the email, so the account can earn points at once.
+// There is no password; one can be set through the forgot password flow.
+func (us *UserService) CreateExternalUser(ctx context.Context, username, email string) (*model.User, error) {
+	count, err := us.collection.CountDocuments(ctx, emailTakenFilter(email))
 	if err != nil {
 		return nil, err
 	}
+	if count > 0 {
+		return nil, ErrEmailExists
+	}
 
-	var users []bson.M
-	if err = cursor.All(ctx, &users); err != nil {
+	now := time.Now()
+	user := &model.User{
+		ID:              primitive.NewObjectID(),
+		Username:        username,
+		Email:           email,
+		Role:            model.RoleUser,
+		NormalizedEmail: NormalizeEmail(email),
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+	if err := us.insertUser(ctx, user); err != nil {
 		return nil, err
 	}
 
+	return user, nil
+}
+
+// insertUser stores a new user with a fresh referral code
+func (us *UserService) insertUser(ctx context.Context, user *model.User) error {
+	var err error
+	// Retry on the (very unlikely) event of a referral code collision
+	for attempt := 0; attempt < 3; attempt++ {
+		user.ReferralCode, err = generateReferralCode()
+		if err != nil {
+			return err
+		}
+
+		_, err = us.collection.InsertOne(ctx, user)
+		if isNormalizedEmailConflict(err) {
+			return ErrEmailExists // The same mailbox registered concurrently
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	return err
+}
+
+// isNormalizedEmailConflict reports whether err is a duplicate key error on
+// the normalized_email index
+func isNormalizedEmailConflict(err error) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "normalized_email")
+}
+
+// SearchUsers returns one page of users matching search, newest first
+// Used by admin GET /api/admin/users
+// Returns: users for the page and the total number of matches
+func (us *UserService) SearchUsers(ctx context.Context, search model.UserSearch, page, limit int64) ([]model.User, int64, error) {
+	filter := bson.M{}
+	if query := strings.TrimSpace(search.Query); query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"username": pattern}, bson.M{"email": pattern}}
+	}
+	if search.Role != "" {
+		filter["role"] = search.Role
+	}
+	if search.Suspended != nil {
+		if *search.Suspended {
+			filter["suspended"] = true
+		} else {
+			filter["suspended"] = bson.M{"$ne": true}
+		}
+	}
+
+	total, err := us.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := us.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer cursor.Close(ctx)
-	return users, nil
+
+	var users []model.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetRole changes a user's role
+// adminID is the admin making the change; admins cannot demote themselves so
+// the last admin can never lock everyone out
+// Returns the updated user
+func (us *UserService) SetRole(ctx context.Context, adminID, userID, role string) (*model.User, error) {
+	if !validRoles[role] {
+		return nil, fmt.Errorf("%w %q", ErrInvalidRole, role)
+	}
+	if adminID == userID {
+		return nil, ErrSelfModification
+	}
+
+	return us.updateUser(ctx, userID, bson.M{"$set": bson.M{"role": role}})
 }
 
-// GetUserByID retrieves a single user by ID
-func (us *UserService) GetUserByID(ctx context.Context, userID string) (bson.M, error) {
+// SetSuspended suspends or reinstates a user
+// A suspended user can no longer log in or refresh tokens; the caller should
+// also revoke the user's sessions so issued access tokens stop working.
+// Returns the updated user
+func (us *UserService) SetSuspended(ctx context.Context, adminID, userID string, suspended bool, reason string) (*model.User, error) {
+	if adminID == userID {
+		return nil, ErrSelfModification
+	}
+
+	update := bson.M{"$unset": bson.M{"suspended": "", "suspended_at": "", "suspension_reason": ""}}
+	if suspended {
+		update = bson.M{"$set": bson.M{
+			"suspended":         true,
+			"suspended_at":      time.Now(),
+			"suspension_reason": strings.TrimSpace(reason),
+		}}
+	}
+
+	return us.updateUser(ctx, userID, update)
+}
+
+// updateUser applies update to one user and returns the result
+func (us *UserService) updateUser(ctx context.Context, userID string, update bson.M) (*model.User, error) {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID")
+		return nil, ErrUserNotFound
 	}
 
-	filter := bson.M{"_id": id}
-	var user bson.M
-
-	err = us.collection.FindOne(ctx, filter).Decode(&user)
+	var user model.User
+	err = us.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return &user, nil
 }
 
-// UpdateUser updates a user by ID
-func (us *UserService) UpdateUser(ctx context.Context, userID string, updateData map[string]interface{}) error {
+// ValidatePassword checks a new password before it is stored
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// SetPassword replaces the user's password
+// Used by the password reset flow, which has already proven the user's identity
+func (us *UserService) SetPassword(ctx context.Context, userID, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.updateUser(ctx, userID, bson.M{"$set": bson.M{"password": string(hashedPassword)}})
+	return err
+}
+
+// CheckPassword confirms the user's current password before a sensitive change
+// Returns ErrWrongPassword when password does not match, or ErrPasswordNotSet
+// for an account created through an identity provider that never set one
+func (us *UserService) CheckPassword(ctx context.Context, userID, password string) error {
+	var user model.User
+	if err := us.FindUserByID(ctx, userID, &user); err != nil {
+		return err
+	}
+	return checkPassword(&user, password)
+}
+
+// checkPassword compares password with the user's stored hash
+func checkPassword(user *model.User, password string) error {
+	if user.Password == "" {
+		return ErrPasswordNotSet
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// ChangePassword replaces the user's password after checking the current one
+// Returns ErrWrongPassword when currentPassword does not match (ErrPasswordNotSet
+// without a password: the forgot password flow sets the first one)
+func (us *UserService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	if err := us.CheckPassword(ctx, userID, currentPassword); err != nil {
+		return err
+	}
+
+	return us.SetPassword(ctx, userID, newPassword)
+}
+
+// MarkEmailVerified records that the user owns email
+// Only succeeds while email is still the account's address, so a link sent to
+// a previous address cannot verify a new one
+// Returns the updated user, or ErrUserNotFound when the user or address no longer matches
+func (us *UserService) MarkEmailVerified(ctx context.Context, userID, email string) (*model.User, error) {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID")
+		return nil, ErrUserNotFound
 	}
 
-	filter := bson.M{"_id": id}
-	update := bson.M{"$set": updateData}
+	var user model.User
+	err = us.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "email": email},
+		bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
 
-	result, err := us.collection.UpdateOne(ctx, filter, update)
+// BackfillNormalizedEmails sets normalized_email on every account from its email
+// Accounts created before referrals had none, so their aliases slipped past
+// ResolveReferrer. When several older accounts share a mailbox only the oldest
+// keeps the normalized address, which the unique index then requires; the
+// mailbox stays taken either way. Run from runMigrations on startup.
+func (us *UserService) BackfillNormalizedEmails(ctx context.Context) error {
+	cursor, err := us.collection.Find(ctx, bson.M{},
+		options.Find().
+			SetProjection(bson.M{"email": 1, "normalized_email": 1}).
+			SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
 	if err != nil {
 		return err
 	}
+	defer cursor.Close(ctx)
 
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("user not found")
+	seen := make(map[string]bool)
+	for cursor.Next(ctx) {
+		var user model.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		normalized := NormalizeEmail(user.Email)
+		var update bson.M
+		switch {
+		case seen[normalized]:
+			if user.NormalizedEmail == "" {
+				continue
+			}
+			update = bson.M{"$unset": bson.M{"normalized_email": ""}}
+		case user.NormalizedEmail != normalized:
+			update = bson.M{"$set": bson.M{"normalized_email": normalized}}
+		}
+		seen[normalized] = true
+		if update == nil {
+			continue
+		}
+
+		if _, err := us.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			return err
+		}
 	}
+	return cursor.Err()
+}
 
-	return nil
+// GrandfatherVerifiedEmails marks the accounts that predate email
+// verification as verified, so they keep earning and stay on the leaderboard
+// Those accounts have no email_verified field at all; email_verified_at stays
+// unset, telling them apart from addresses verified through a link. Only
+// accounts created before the migration started are touched. Run from
+// runMigrations on startup.
+func (us *UserService) GrandfatherVerifiedEmails(ctx context.Context) error {
+	_, err := us.collection.UpdateMany(ctx,
+		bson.M{
+			"_id":            bson.M{"$lt": primitive.NewObjectIDFromTimestamp(time.Now())},
+			"email_verified": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	return err
+}
+
+// IsEmailVerified reports whether the user has verified their email
+// Used by middleware.RequireVerifiedEmail on the point-earning routes
+func (us *UserService) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, ErrUserNotFound
+	}
+
+	var user model.User
+	err = us.collection.FindOne(ctx, bson.M{"_id": id},
+		options.FindOne().SetProjection(bson.M{"email_verified": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return user.EmailVerified, nil
+}
+
+// UpdateProfile applies a user's own profile changes
+// Only the fields of model.ProfileUpdate can be changed. A new email needs the
+// current password (an account without one gets ErrPasswordNotSet), must not
+// belong to another account (as the same address or an alias of the same
+// mailbox) and resets the verification, so the account stops earning until the
+// new address is verified.
+// The timezone can change once per timezoneChangeCooldown (the first change is
+// always allowed).
+// Returns the updated user and whether the email changed; ErrInvalidProfile
+// (wrapped with the reason), ErrInvalidTimezone, *TimezoneCooldownError,
+// ErrWrongPassword, ErrPasswordNotSet or ErrEmailExists
+func (us *UserService) UpdateProfile(ctx context.Context, userID string, input model.ProfileUpdate) (*model.User, bool, error) {
+	var user model.User
+	if err := us.FindUserByID(ctx, userID, &user); err != nil {
+		return nil, false, err
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	setOrUnset := func(field, value string) {
+		if value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+
+	if input.Username != nil {
+		username := strings.TrimSpace(*input.Username)
+		if length := utf8.RuneCountInString(username); length < minUsernameLength || length > maxUsernameLength {
+			return nil, false, fmt.Errorf("%w: username must be %d to %d characters", ErrInvalidProfile, minUsernameLength, maxUsernameLength)
+		}
+		if hasControlCharacters(username) {
+			return nil, false, fmt.Errorf("%w: username contains invalid characters", ErrInvalidProfile)
+		}
+		set["username"] = username
+	}
+
+	if input.DisplayName != nil {
+		displayName := strings.TrimSpace(*input.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+			return nil, false, fmt.Errorf("%w: displayName must be at most %d characters", ErrInvalidProfile, maxDisplayNameLength)
+		}
+		if hasControlCharacters(displayName) {
+			return nil, false, fmt.Errorf("%w: displayName contains invalid characters", ErrInvalidProfile)
+		}
+		setOrUnset("display_name", displayName)
+	}
+
+	if input.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*input.AvatarURL)
+		if avatarURL != "" {
+			parsed, err := url.Parse(avatarURL)
+			if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil || len(avatarURL) > maxAvatarURLLength {
+				return nil, false, fmt.Errorf("%w: avatarUrl must be an https URL of at most %d characters", ErrInvalidProfile, maxAvatarURLLength)
+			}
+		}
+		setOrUnset("avatar_url", avatarURL)
+	}
+
+	if input.Timezone != nil && *input.Timezone != user.Timezone {
+		if _, err := LoadTimezone(*input.Timezone); err != nil {
+			return nil, false, err
+		}
+		now := time.Now()
+		if user.TimezoneChangedAt != nil {
+			if remaining := user.TimezoneChangedAt.Add(timezoneChangeCooldown).Sub(now); remaining > 0 {
+				return nil, false, &TimezoneCooldownError{Remaining: remaining}
+			}
+		}
+		setOrUnset("timezone", *input.Timezone)
+		set["timezone_changed_at"] = now
+	}
+
+	emailChanged := false
+	if input.Email != nil {
+		email := strings.TrimSpace(*input.Email)
+		if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+			return nil, false, fmt.Errorf("%w: email is not a valid address", ErrInvalidProfile)
+		}
+
+		if email != user.Email {
+			if err := checkPassword(&user, input.CurrentPassword); err != nil {
+				return nil, false, err
+			}
+			filter := emailTakenFilter(email)
+			filter["_id"] = bson.M{"$ne": user.ID}
+			count, err := us.collection.CountDocuments(ctx, filter)
+			if err != nil {
+				return nil, false, err
+			}
+			if count > 0 {
+				return nil, false, ErrEmailExists
+			}
+
+			set["email"] = email
+			set["normalized_email"] = NormalizeEmail(email)
+			unset["email_verified"] = ""
+			unset["email_verified_at"] = ""
+			emailChanged = true
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		return &user, false, nil
+	}
+
+	updated, err := us.updateUser(ctx, userID, update)
+	if isNormalizedEmailConflict(err) {
+		return nil, false, ErrEmailExists
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return updated, emailChanged, nil
+}
+
+// hasControlCharacters reports whether s contains line breaks, tabs or other
+// non-printing characters
+func hasControlCharacters(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
 }
 
 // DeleteUser deletes a user by ID
 func (us *UserService) DeleteUser(ctx context.Context, userID string) error {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID")
+		return ErrUserNotFound
 	}
 
 	filter := bson.M{"_id": id}
@@ -132,62 +613,35 @@ func (us *UserService) DeleteUser(ctx context.Context, userID string) error {
 	}
 
 	if result.DeletedCount == 0 {
-		return fmt.Errorf("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
-// DeleteAllUsers deletes all users from the database
-func (us *UserService) DeleteAllUsers(ctx context.Context) (int64, error) {
-	result, err := us.collection.DeleteMany(ctx, bson.D{})
-	if err != nil {
-		return 0, err
-	}
-
-	return result.DeletedCount, nil
-}
-
 // FindUserByEmail retrieves a user by email address
 // Added to fix undefined method error in auth_controller.go
 // This method queries the database for a user matching the provided email
 func (us *UserService) FindUserByEmail(ctx context.Context, email string, user *model.User) error {
 	filter := bson.M{"email": email}
 	err := us.collection.FindOne(ctx, filter).Decode(user)
-	if err != nil {
-		return err
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
 	}
-	return nil
+	return err
 }
 
 // Added FindUserByID for internal use, returns model.User
 func (us *UserService) FindUserByID(ctx context.Context, userID string, user *model.User) error {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return fmt.Errorf("invalid user ID")
+		return ErrUserNotFound
 	}
 
 	filter := bson.M{"_id": id}
 	err = us.collection.FindOne(ctx, filter).Decode(user)
-	if err != nil {
-		return err
+	if err == mongo.ErrNoDocuments {
+		return ErrUserNotFound
 	}
-	return nil
-}
-
-// Added BlacklistToken for logout functionality
-func (bs *BlacklistService) BlacklistToken(ctx context.Context, token string) error {
-	blacklisted := model.BlacklistedToken{Token: token}
-	_, err := bs.collection.InsertOne(ctx, blacklisted)
 	return err
 }
-
-// Added IsTokenBlacklisted for checking if token is blacklisted
-func (bs *BlacklistService) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	filter := bson.M{"token": token}
-	count, err := bs.collection.CountDocuments(ctx, filter)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
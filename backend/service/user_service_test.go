@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"rewardpage/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGrandfatherVerifiedEmails(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// An account from before email verification existed, stored without the field
+	legacyID := primitive.NewObjectIDFromTimestamp(time.Now().AddDate(-1, 0, 0))
+	_, err := db.Collection(colName).InsertOne(ctx, bson.M{
+		"_id":      legacyID,
+		"username": "legacy",
+		"email":    "legacy@example.com",
+		"role":     model.RoleUser,
+		"points":   40,
+	})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	if err := UserServiceInstance.GrandfatherVerifiedEmails(ctx); err != nil {
+		t.Fatalf("GrandfatherVerifiedEmails: %v", err)
+	}
+
+	verified, err := UserServiceInstance.IsEmailVerified(ctx, legacyID.Hex())
+	if err != nil {
+		t.Fatalf("IsEmailVerified: %v", err)
+	}
+	if !verified {
+		t.Error("legacy account is not verified")
+	}
+
+	var user model.User
+	if err := UserServiceInstance.FindUserByID(ctx, legacyID.Hex(), &user); err != nil {
+		t.Fatalf("FindUserByID: %v", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		t.Errorf("email_verified_at = %v, want unset", user.EmailVerifiedAt)
+	}
+}
+
+func TestBackfillNormalizedEmails(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// Two accounts from before referrals, both aliases of one mailbox
+	older := primitive.NewObjectIDFromTimestamp(time.Now().AddDate(-1, 0, 0))
+	newer := primitive.NewObjectIDFromTimestamp(time.Now().AddDate(0, -1, 0))
+	for id, email := range map[primitive.ObjectID]string{older: "Jane.Doe@gmail.com", newer: "janedoe+promo@gmail.com"} {
+		if _, err := db.Collection(colName).InsertOne(ctx, bson.M{"_id": id, "email": email}); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	if err := UserServiceInstance.BackfillNormalizedEmails(ctx); err != nil {
+		t.Fatalf("BackfillNormalizedEmails: %v", err)
+	}
+
+	var user model.User
+	if err := UserServiceInstance.FindUserByID(ctx, older.Hex(), &user); err != nil {
+		t.Fatalf("FindUserByID: %v", err)
+	}
+	if user.NormalizedEmail != "janedoe@gmail.com" {
+		t.Errorf("older account normalized_email = %q, want janedoe@gmail.com", user.NormalizedEmail)
+	}
+	user = model.User{}
+	if err := UserServiceInstance.FindUserByID(ctx, newer.Hex(), &user); err != nil {
+		t.Fatalf("FindUserByID: %v", err)
+	}
+	if user.NormalizedEmail != "" {
+		t.Errorf("newer account normalized_email = %q, want unset", user.NormalizedEmail)
+	}
+}
+
+func TestUpdateProfileRejectsMailboxAlias(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	if _, err := UserServiceInstance.CreateUser(ctx, model.UserInput{Username: "jane", Email: "janedoe@gmail.com", Password: "password123"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	other, err := UserServiceInstance.CreateUser(ctx, model.UserInput{Username: "other", Email: "other@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	alias := "jane.doe+new@gmail.com"
+	_, _, err = UserServiceInstance.UpdateProfile(ctx, other.ID.Hex(), model.ProfileUpdate{Email: &alias, CurrentPassword: "password123"})
+	if !errors.Is(err, ErrEmailExists) {
+		t.Errorf("UpdateProfile error = %v, want %v", err, ErrEmailExists)
+	}
+
+	if _, err := UserServiceInstance.CreateUser(ctx, model.UserInput{Username: "alias", Email: alias, Password: "password123"}); !errors.Is(err, ErrEmailExists) {
+		t.Errorf("CreateUser error = %v, want %v", err, ErrEmailExists)
+	}
+
+	count, err := db.Collection(colName).CountDocuments(ctx, bson.M{"normalized_email": "janedoe@gmail.com"})
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d accounts for the mailbox, want 1", count)
+	}
+}
+
+func TestUpdateProfileEmailWithoutPassword(t *testing.T) {
+	newTestDB(t)
+	ctx := context.Background()
+
+	user, err := UserServiceInstance.CreateExternalUser(ctx, "jane", "jane@example.com")
+	if err != nil {
+		t.Fatalf("CreateExternalUser: %v", err)
+	}
+
+	email := "jane@example.org"
+	_, _, err = UserServiceInstance.UpdateProfile(ctx, user.ID.Hex(), model.ProfileUpdate{Email: &email})
+	if !errors.Is(err, ErrPasswordNotSet) {
+		t.Errorf("UpdateProfile error = %v, want %v", err, ErrPasswordNotSet)
+	}
+
+	// Once the forgot password flow has set one, the change goes through
+	if err := UserServiceInstance.SetPassword(ctx, user.ID.Hex(), "password123"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	_, changed, err := UserServiceInstance.UpdateProfile(ctx, user.ID.Hex(), model.ProfileUpdate{Email: &email, CurrentPassword: "password123"})
+	if err != nil || !changed {
+		t.Errorf("UpdateProfile with password = %v, changed %v", err, changed)
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the address of the client that sent r
+// X-Forwarded-For is only trusted when TRUST_PROXY is set, i.e. when the API
+// runs behind reverse proxies that append to it. The client can put anything
+// in the header before the first proxy, so the address is read from the right:
+// the entry appended by the outermost of the trusted proxies.
+func ClientIP(r *http.Request) string {
+	if hops := trustedProxyHops(); hops > 0 {
+		if ip, ok := forwardedClientIP(r.Header.Values("X-Forwarded-For"), hops); ok {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// trustedProxyHops reads TRUST_PROXY: "true" for a single reverse proxy, or
+// the number of proxies in front of the API; 0 when the header is not trusted
+func trustedProxyHops() int {
+	value := os.Getenv("TRUST_PROXY")
+	if value == "true" {
+		return 1
+	}
+	hops, err := strconv.Atoi(value)
+	if err != nil || hops < 0 {
+		return 0
+	}
+	return hops
+}
+
+// forwardedClientIP picks the entry hops places from the right of the
+// X-Forwarded-For values (repeated headers count as one comma-separated list)
+// Returns false when there are fewer entries than trusted proxies or the entry
+// is not an IP address, so the connection's own address is used instead
+func forwardedClientIP(values []string, hops int) (string, bool) {
+	var entries []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	if len(entries) < hops {
+		return "", false
+	}
+
+	ip := net.ParseIP(entries[len(entries)-hops])
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
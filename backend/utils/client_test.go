@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy string
+		forwarded  []string
+		want       string
+	}{
+		{"proxy not trusted", "", []string{"203.0.113.7"}, "192.0.2.1"},
+		{"single proxy", "true", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry before the proxy's", "true", []string{"198.51.100.99, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofed header repeated", "true", []string{"198.51.100.99", "203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", "2", []string{"198.51.100.99, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"fewer entries than proxies", "2", []string{"203.0.113.7"}, "192.0.2.1"},
+		{"entry is not an address", "true", []string{"203.0.113.7, garbage"}, "192.0.2.1"},
+		{"no header", "true", nil, "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY", tt.trustProxy)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:4711"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// A client that changes X-Forwarded-For on every request must still land in
+// one rate-limit bucket
+func TestClientIPIgnoresRotatingSpoofedHeader(t *testing.T) {
+	t.Setenv("TRUST_PROXY", "true")
+
+	seen := map[string]bool{}
+	for _, spoofed := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Forwarded-For", spoofed+", 203.0.113.7")
+		seen[ClientIP(r)] = true
+	}
+	if len(seen) != 1 || !seen["203.0.113.7"] {
+		t.Errorf("client addresses = %v, want only 203.0.113.7", seen)
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Generic error codes used across the API
+// Endpoint-specific codes (e.g. "cooldown_active") are defined next to the
+// service errors they describe in controller/errors.go
+const (
+	CodeBadRequest   = "bad_request"       // Malformed body or missing field
+	CodeValidation   = "validation_failed" // Well-formed but invalid input
+	CodeUnauthorized = "unauthorized"      // Missing, invalid or revoked credentials
+	CodeForbidden    = "forbidden"         // Authenticated but not allowed
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+)
+
+// APIError is the body of every error response:
+// { "error": { "code", "message", "details"?, "requestId" } }
+// Frontend switches on Code; Message is for display; Details carries extra
+// machine-readable data (e.g. remainingSeconds); RequestID matches the
+// X-Request-ID response header and the server logs
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"requestId,omitempty"`
+}
+
+// errorEnvelope wraps APIError under the "error" key
+type errorEnvelope struct {
+	Error APIError `json:"error"`
+}
+
+// WriteError sends the standard error envelope with the given status
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestIDFromContext(r.Context()),
+	}})
+}
+
+// requestIDKey stores the request ID in the request context
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+// Set by middleware.RequestID for every request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID, or "" outside a request
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -2,6 +2,10 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -10,81 +14,210 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+// JWT "typ" header values; checked on parse so a refresh token can never be
+// used as an access token or the other way round
+const (
+	TokenTypeAccess  = "at+jwt"
+	TokenTypeRefresh = "refresh+jwt"
+	TokenTypeMFA     = "mfa+jwt"
+)
+
+// AccessTokenTTL is the lifetime of access tokens
+const AccessTokenTTL = 15 * time.Minute
+
+// MFAChallengeTTL is how long a login may take to enter its second factor
+const MFAChallengeTTL = 5 * time.Minute
+
+// jwtIssuer is the iss claim of every token (JWT_ISSUER, default "rewardpage")
+// Refresh tokens use it as their audience too: only this API consumes them
+func jwtIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "rewardpage"
+}
+
+// jwtAudience is the aud claim of access tokens (JWT_AUDIENCE, default "rewardpage-api")
+func jwtAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return "rewardpage-api"
+}
 
 type Claims struct {
-	UserID string `json:"userID"`
-	Email  string `json:"email"`
-	Role   string `json:"role"` // Added role for role-based authorization
+	UserID    string `json:"userID"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`          // Added role for role-based authorization
+	SessionID string `json:"sid,omitempty"` // Refresh token family the token was issued for
 	jwt.RegisteredClaims
 }
 
 // Added RefreshClaims for refresh tokens
+// RegisteredClaims.ID (jti) identifies the token within its family
 type RefreshClaims struct {
+	UserID   string `json:"userID"`
+	FamilyID string `json:"fid"`
+	jwt.RegisteredClaims
+}
+
+// MFAClaims is the challenge token Login returns when the user has 2FA enabled
+// It only proves the password was right; POST /api/users/login/mfa exchanges
+// it, together with a TOTP or recovery code, for access and refresh tokens
+type MFAClaims struct {
 	UserID string `json:"userID"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID, email, role string) (string, error) {
+// GenerateToken issues a 15-minute access token
+// sessionID ties it to the login (refresh token family) it belongs to
+func GenerateToken(userID, email, role, sessionID string) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role, // Added role to claims
+		UserID:    userID,
+		Email:     email,
+		Role:      role, // Added role to claims
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)), // Short-lived access token
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        tokenID, // jti, used to blacklist the token on logout
+			Issuer:    jwtIssuer(),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{jwtAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)), // Short-lived access token
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return signToken(TokenTypeAccess, claims)
 }
 
 // Added GenerateRefreshToken for refresh functionality
-func GenerateRefreshToken(userID string) (string, error) {
+// familyID and tokenID come from the persisted refresh token family, which
+// decides whether the token may still be used
+func GenerateRefreshToken(userID, familyID, tokenID string, expiresAt time.Time) (string, error) {
 	claims := &RefreshClaims{
-		UserID: userID,
+		UserID:   userID,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // Long-lived refresh token
+			ID:        tokenID,
+			Issuer:    jwtIssuer(),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{jwtIssuer()},
+			ExpiresAt: jwt.NewNumericDate(expiresAt), // Long-lived refresh token
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return signToken(TokenTypeRefresh, claims)
 }
 
-// Added ValidateRefreshToken for refresh endpoint
-func ValidateRefreshToken(tokenStr string) (*RefreshClaims, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenStr,
-		&RefreshClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		},
-	)
+// GenerateMFAToken issues a challenge token for a user whose password matched
+// Like refresh tokens it is addressed to this API only
+func GenerateMFAToken(userID string) (string, error) {
+	tokenID, err := newTokenID()
 	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &MFAClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID, // jti, blacklisted once the challenge is completed
+			Issuer:    jwtIssuer(),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{jwtIssuer()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return signToken(TokenTypeMFA, claims)
+}
+
+// ValidateMFAToken checks a challenge token and returns its claims
+func ValidateMFAToken(tokenStr string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	if err := parseToken(tokenStr, TokenTypeMFA, jwtIssuer(), claims); err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*RefreshClaims); ok && token.Valid {
-		return claims, nil
+	return claims, nil
+}
+
+// Added ValidateRefreshToken for refresh endpoint
+func ValidateRefreshToken(tokenStr string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := parseToken(tokenStr, TokenTypeRefresh, jwtIssuer(), claims); err != nil {
+		return nil, err
 	}
-	return nil, err
+	return claims, nil
 }
 
+// ValidateToken checks an access token and returns its claims
 func ValidateToken(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenStr,
-		&Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		},
-	)
-	if err != nil {
+	claims := &Claims{}
+	if err := parseToken(tokenStr, TokenTypeAccess, jwtAudience(), claims); err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*Claims); ok {
-		return claims, nil
+	return claims, nil
+}
+
+// signToken signs claims with the current key, setting the kid and typ headers
+func signToken(typ string, claims jwt.Claims) (string, error) {
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = typ
+	return token.SignedString(key.Private)
+}
+
+// parseToken verifies a token into claims
+// Enforces the typ header, a known kid whose algorithm matches the token's alg
+// (so an HS256 token or an alg swap is rejected), the issuer, the audience
+// and a required expiry
+func parseToken(tokenStr, typ, audience string, claims jwt.Claims) error {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{AlgEdDSA, AlgRS256}),
+		jwt.WithIssuer(jwtIssuer()),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	_, err := parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != typ {
+			return nil, fmt.Errorf("token type %v is not %s", token.Header["typ"], typ)
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationKey(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("token algorithm %s does not match key %s", token.Method.Alg(), kid)
+		}
+		return key.Public, nil
+	})
+	return err
+}
+
+// newTokenID returns 16 random bytes as hex for a token's jti
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
-	return nil, err
+	return hex.EncodeToString(buf), nil
 }
 
 // CHANGE: ExtractUserIDFromToken extracts user ID from JWT token in request header
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported for JWTs
+const (
+	AlgEdDSA = "EdDSA" // Ed25519 (default)
+	AlgRS256 = "RS256" // RSA 2048 with SHA-256
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// SigningKey is one asymmetric key pair used for JWTs, identified by its kid
+// Private is nil for keys that are only used to verify
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// keySet holds the key used to sign new tokens and every key still accepted
+// Filled by service.SigningKeyService, which persists and rotates the keys
+var keySet struct {
+	sync.RWMutex
+	signing *SigningKey
+	byID    map[string]*SigningKey
+}
+
+// SetSigningKeys installs the current signing key and all verification keys
+// signing must also be in verify
+func SetSigningKeys(signing *SigningKey, verify []*SigningKey) {
+	byID := make(map[string]*SigningKey, len(verify))
+	for _, key := range verify {
+		byID[key.ID] = key
+	}
+
+	keySet.Lock()
+	defer keySet.Unlock()
+	keySet.signing = signing
+	keySet.byID = byID
+}
+
+// currentSigningKey returns the key for new tokens
+func currentSigningKey() (*SigningKey, error) {
+	keySet.RLock()
+	defer keySet.RUnlock()
+	if keySet.signing == nil {
+		return nil, errors.New("no JWT signing key loaded")
+	}
+	return keySet.signing, nil
+}
+
+// verificationKey returns the key a token's kid refers to
+func verificationKey(kid string) (*SigningKey, bool) {
+	keySet.RLock()
+	defer keySet.RUnlock()
+	key, ok := keySet.byID[kid]
+	return key, ok
+}
+
+// signingMethod maps an algorithm name to its jwt implementation
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	case AlgRS256:
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+}
+
+// GenerateSigningKey creates a new key pair with a random kid
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, err
+	}
+	key := &SigningKey{ID: hex.EncodeToString(kidBytes), Algorithm: alg}
+
+	switch alg {
+	case AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.Private, key.Public = private, public
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.Private, key.Public = private, &private.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+
+	return key, nil
+}
+
+// EncodePrivateKey returns the key's private half as a PKCS#8 PEM block
+func EncodePrivateKey(key *SigningKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// DecodeSigningKey rebuilds a key from its kid, algorithm and PKCS#8 PEM
+func DecodeSigningKey(id, alg, privatePEM string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s: invalid PEM", id)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", id, err)
+	}
+
+	key := &SigningKey{ID: id, Algorithm: alg}
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		if alg != AlgEdDSA {
+			return nil, fmt.Errorf("signing key %s: Ed25519 key stored as %s", id, alg)
+		}
+		key.Private, key.Public = private, private.Public()
+	case *rsa.PrivateKey:
+		if alg != AlgRS256 {
+			return nil, fmt.Errorf("signing key %s: RSA key stored as %s", id, alg)
+		}
+		key.Private, key.Public = private, &private.PublicKey
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported key type %T", id, parsed)
+	}
+
+	return key, nil
+}
+
+// sealedKeyPrefix marks private keys encrypted by KeySealer; keys stored before
+// encryption at rest are plain PEM
+const sealedKeyPrefix = "aes-gcm:"
+
+// minKeySecretLength is the shortest SIGNING_KEY_SECRET accepted
+const minKeySecretLength = 32
+
+// KeySealer encrypts signing keys (and other secrets, such as TOTP secrets) at
+// rest with AES-256-GCM
+// The AES key is derived from SIGNING_KEY_SECRET, which never reaches MongoDB,
+// so a database dump alone does not allow forging tokens or 2FA codes.
+type KeySealer struct {
+	aead cipher.AEAD
+}
+
+// NewKeySealer creates a sealer from secret (at least 32 characters)
+func NewKeySealer(secret string) (*KeySealer, error) {
+	if len(secret) < minKeySecretLength {
+		return nil, fmt.Errorf("SIGNING_KEY_SECRET must be at least %d characters", minKeySecretLength)
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySealer{aead: aead}, nil
+}
+
+// Seal encrypts a private key PEM for storage
+// The kid is authenticated along with it, so a sealed key only opens for the
+// record it was written to
+func (ks *KeySealer) Seal(id, privatePEM string) (string, error) {
+	nonce := make([]byte, ks.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := ks.aead.Seal(nonce, nonce, []byte(privatePEM), []byte(id))
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a key written by Seal
+func (ks *KeySealer) Open(id, stored string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedKeyPrefix))
+	if err != nil || len(sealed) < ks.aead.NonceSize() {
+		return "", fmt.Errorf("sealed secret %s: invalid encrypted value", id)
+	}
+	nonce, ciphertext := sealed[:ks.aead.NonceSize()], sealed[ks.aead.NonceSize():]
+	plain, err := ks.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("sealed secret %s: cannot decrypt, check SIGNING_KEY_SECRET", id)
+	}
+	return string(plain), nil
+}
+
+// IsSealedKey reports whether a stored private key was written by Seal
+func IsSealedKey(stored string) bool {
+	return strings.HasPrefix(stored, sealedKeyPrefix)
+}
+
+// JWK is the public half of a signing key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"` // OKP keys
+	X         string `json:"x,omitempty"`   // OKP keys
+	N         string `json:"n,omitempty"`   // RSA keys
+	E         string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKS returns the public keys tokens may currently be signed with
+// Served at GET /.well-known/jwks.json so other services can verify tokens
+func JWKS() map[string][]JWK {
+	keySet.RLock()
+	defer keySet.RUnlock()
+
+	keys := make([]JWK, 0, len(keySet.byID))
+	for _, key := range keySet.byID {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+		switch public := key.Public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KeyID < keys[j].KeyID })
+
+	return map[string][]JWK{"keys": keys}
+}
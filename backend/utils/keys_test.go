@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKeySecret = "0123456789abcdef0123456789abcdef"
+
+func TestKeySealerRoundTrip(t *testing.T) {
+	sealer, err := NewKeySealer(testKeySecret)
+	if err != nil {
+		t.Fatalf("NewKeySealer: %v", err)
+	}
+	key, err := GenerateSigningKey(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	privatePEM, err := EncodePrivateKey(key)
+	if err != nil {
+		t.Fatalf("EncodePrivateKey: %v", err)
+	}
+
+	sealed, err := sealer.Seal(key.ID, privatePEM)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealedKey(sealed) || strings.Contains(sealed, "PRIVATE KEY") {
+		t.Fatalf("sealed key is not encrypted: %q", sealed)
+	}
+
+	opened, err := sealer.Open(key.ID, sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if opened != privatePEM {
+		t.Error("opened key differs from the original")
+	}
+	if _, err := DecodeSigningKey(key.ID, AlgEdDSA, opened); err != nil {
+		t.Errorf("DecodeSigningKey: %v", err)
+	}
+}
+
+func TestKeySealerRejectsWrongSecretOrKid(t *testing.T) {
+	sealer, _ := NewKeySealer(testKeySecret)
+	sealed, err := sealer.Seal("kid-1", "pem")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if _, err := sealer.Open("kid-2", sealed); err == nil {
+		t.Error("key opened for another kid")
+	}
+
+	other, _ := NewKeySealer(strings.Repeat("x", 32))
+	if _, err := other.Open("kid-1", sealed); err == nil {
+		t.Error("key opened with another secret")
+	}
+}
+
+func TestNewKeySealerRequiresLongSecret(t *testing.T) {
+	if _, err := NewKeySealer("short"); err == nil {
+		t.Error("short secret accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailMessage is a plain-text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender delivers emails (password reset links, verification links)
+// Choose the implementation with NewMailSenderFromEnv
+type MailSender interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// NewMailSenderFromEnv builds the sender configured by MAIL_TRANSPORT
+//   - "smtp": SMTPMailSender from SMTP_HOST, SMTP_PORT (default 587),
+//     SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM
+//   - "log" or unset: LogMailSender writing to MAIL_LOG_FILE, or the server
+//     log when that is unset (local development and tests)
+func NewMailSenderFromEnv() (MailSender, error) {
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "log":
+		return &LogMailSender{Path: os.Getenv("MAIL_LOG_FILE"), From: os.Getenv("MAIL_FROM")}, nil
+	case "smtp":
+		sender := &SMTPMailSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if sender.Port == "" {
+			sender.Port = "587"
+		}
+		if sender.Host == "" || sender.From == "" {
+			return nil, errors.New("MAIL_TRANSPORT=smtp requires SMTP_HOST and MAIL_FROM")
+		}
+		return sender, nil
+	default:
+		return nil, fmt.Errorf("MAIL_TRANSPORT must be smtp or log, got %q", transport)
+	}
+}
+
+// SMTPMailSender sends mail through an SMTP server
+// STARTTLS is used whenever the server offers it; credentials are only sent
+// over TLS (or to localhost, see smtp.PlainAuth)
+type SMTPMailSender struct {
+	Host     string
+	Port     string
+	Username string // Empty = no authentication
+	Password string
+	From     string
+}
+
+// Send delivers msg, giving up when ctx is done
+func (s *SMTPMailSender) Send(ctx context.Context, msg MailMessage) error {
+	data, err := formatMail(s.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LogMailSender "sends" mail by appending it to a file, or to the server log
+// when Path is empty, so links can be followed without a mail server
+type LogMailSender struct {
+	Path string
+	From string // Optional, only shown in the output
+
+	mu sync.Mutex
+}
+
+// Send writes msg to the file or log
+func (s *LogMailSender) Send(ctx context.Context, msg MailMessage) error {
+	from := s.From
+	if from == "" {
+		from = "noreply@localhost"
+	}
+	data, err := formatMail(from, msg)
+	if err != nil {
+		return err
+	}
+
+	if s.Path == "" {
+		log.Printf("mail (not sent, MAIL_TRANSPORT=log):\n%s", data)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\r\n\r\n", data)
+	return err
+}
+
+// formatMail renders msg as an RFC 5322 message
+// Header values containing line breaks are rejected (header injection)
+func formatMail(from string, msg MailMessage) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,360 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcHTTPTimeout bounds each request to an identity provider
+const oidcHTTPTimeout = 10 * time.Second
+
+// oidcJWKSRefreshInterval is the minimum time between two JWKS downloads
+// An ID token with an unknown kid triggers a download (the provider rotated
+// its keys), but a flood of forged kids cannot make us hammer the provider
+const oidcJWKSRefreshInterval = time.Minute
+
+// oidcHTTPClient is shared by all providers
+var oidcHTTPClient = &http.Client{Timeout: oidcHTTPTimeout}
+
+// OIDCProvider is an OpenID Connect identity provider users can sign in with
+// Endpoints are discovered from Issuer/.well-known/openid-configuration on
+// first use, so the API starts even while a provider is unreachable
+type OIDCProvider struct {
+	Name         string // Used in routes: /api/auth/oidc/{name}/...
+	Issuer       string
+	ClientID     string
+	ClientSecret string   // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string   // Our callback URL, registered with the provider
+	Scopes       []string // Always includes openid and email
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{} // kid -> public key
+	keysAt    time.Time
+}
+
+// oidcDiscovery is the part of the provider metadata we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is what we learn about the user from a verified ID token
+type OIDCIdentity struct {
+	Subject       string // Stable user ID at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string // preferred_username
+}
+
+// oidcIDTokenClaims are the ID token claims we check
+// email_verified is a boolean, but some providers send it as a string
+type oidcIDTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+	Username      string      `json:"preferred_username"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// OIDCProvidersFromEnv reads the providers listed in OIDC_PROVIDERS
+// OIDC_PROVIDERS is a comma-separated list of names; for each name NAME:
+//   - OIDC_NAME_ISSUER and OIDC_NAME_CLIENT_ID (required)
+//   - OIDC_NAME_CLIENT_SECRET (optional)
+//   - OIDC_NAME_REDIRECT_URL (default http://localhost:4000/api/auth/oidc/name/callback)
+//   - OIDC_NAME_SCOPES (optional, space-separated, added to "openid email profile")
+//
+// No OIDC_PROVIDERS means social login is off
+func OIDCProvidersFromEnv() (map[string]*OIDCProvider, error) {
+	providers := make(map[string]*OIDCProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       append([]string{"openid", "email", "profile"}, strings.Fields(os.Getenv(prefix+"SCOPES"))...),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %s requires %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if provider.RedirectURL == "" {
+			provider.RedirectURL = "http://localhost:4000/api/auth/oidc/" + name + "/callback"
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier and its S256 challenge (RFC 7636)
+func NewPKCEVerifier() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to for login
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the returned ID token
+// nonce must be the one sent with AuthCodeURL
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := doOIDCRequest(req, &tokens); err != nil {
+		return nil, fmt.Errorf("OIDC provider %s: token exchange: %w", p.Name, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("OIDC provider %s: token response has no id_token", p.Name)
+	}
+
+	return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	claims := &oidcIDTokenClaims{}
+	_, err = parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("OIDC provider %s: invalid ID token: %w", p.Name, err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("OIDC provider %s: ID token nonce mismatch", p.Name)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("OIDC provider %s: ID token has no subject", p.Name)
+	}
+
+	identity := &OIDCIdentity{Subject: claims.Subject, Email: claims.Email, Name: claims.Name, Username: claims.Username}
+	switch verified := claims.EmailVerified.(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity, nil
+}
+
+// discover loads and caches the provider metadata
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery oidcDiscovery
+	if err := doOIDCRequest(req, &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC provider %s: discovery: %w", p.Name, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC provider %s: discovery issuer %q does not match %q", p.Name, discovery.Issuer, p.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC provider %s: discovery document is incomplete", p.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// publicKey returns the provider key with this kid, downloading the JWKS when
+// the kid is unknown (at most once per oidcJWKSRefreshInterval)
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < oidcJWKSRefreshInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			Curve   string `json:"crv"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := doOIDCRequest(req, &set); err != nil {
+		return nil, fmt.Errorf("JWKS: %w", err)
+	}
+	p.keysAt = time.Now()
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key interface{}
+		switch {
+		case jwk.KeyType == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case jwk.KeyType == "EC" && (jwk.Curve == "P-256" || jwk.Curve == "P-384"):
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			curve := elliptic.P256()
+			if jwk.Curve == "P-384" {
+				curve = elliptic.P384()
+			}
+			key = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		case jwk.KeyType == "OKP" && jwk.Curve == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			key = ed25519.PublicKey(x)
+		default:
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// doOIDCRequest sends req and decodes a JSON response into out
+// Error responses include the provider's error and error_description
+func doOIDCRequest(req *http.Request, out interface{}) error {
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &oauthErr)
+		return fmt.Errorf("HTTP %d %s %s", resp.StatusCode, oauthErr.Error, oauthErr.Description)
+	}
+	return json.Unmarshal(body, out)
+}
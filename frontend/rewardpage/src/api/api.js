@@ -34,4 +34,10 @@ api.interceptors.response.use(
     }
 );
 
+// Backend errors use one envelope: { error: { code, message, details?, requestId } }
+// apiError returns that object for any failed request so components can switch
+// on `code` (e.g. 'cooldown_active', 'daily_limit_reached') instead of parsing text
+export const apiError = (err) =>
+    err?.response?.data?.error || { code: 'network_error', message: err?.message || 'Network error' };
+
 export default api;
\ No newline at end of file
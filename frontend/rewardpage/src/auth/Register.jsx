@@ -1,17 +1,26 @@
 import { useState } from "react";
 import api from "../api/api";
-import { useNavigate, Link } from "react-router-dom";
+import { useNavigate, Link, useSearchParams } from "react-router-dom";
 
 const Register = () => {
     const [username, setUsername] = useState("");
     const [email, setEmail] = useState("");
     const [password, setPassword] = useState("");
+    // Invite links look like /register?ref=CODE
+    const [searchParams] = useSearchParams();
+    const referralCode = searchParams.get("ref") || "";
     const navigate = useNavigate();
 
     const handleSubmit = async (e) => {
         e.preventDefault();
         try {
-            await api.post("/users/register", { username, email, password });
+            // Browser timezone so daily resets happen at the user's own midnight
+            const timezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+            const payload = { username, email, password, timezone };
+            if (referralCode) {
+                payload.referralCode = referralCode;
+            }
+            await api.post("/users/register", payload);
             // Registration successful, redirect to login page or directly to reward page
             navigate("/rewardpage");
         } catch (error) {
@@ -104,4 +113,4 @@ const Register = () => {
     );
 };
 
-export default Register;
\ No newline at end of file
+export default Register;
\ No newline at end of file
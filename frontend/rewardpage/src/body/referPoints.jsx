@@ -1,6 +1,27 @@
+import { useState, useEffect } from "react"
 import { PersonStandingIcon } from "lucide-react"
+import api from "../api/api"
 
 export default function ReferPoint(){
+    // Referral stats from GET /api/referrals
+    // { referralCode, referralCount, pointsEarned, pointsPerReferral }
+    const [referrals, setReferrals] = useState({
+        referralCode: "",
+        referralCount: 0,
+        pointsEarned: 0,
+        pointsPerReferral: 25,
+    })
+
+    useEffect(() => {
+        api.get('/referrals')
+            .then(res => setReferrals(res.data))
+            .catch(err => console.error('Failed to load referrals', err))
+    }, [])
+
+    const referralLink = referrals.referralCode
+        ? `${window.location.origin}/register?ref=${referrals.referralCode}`
+        : ""
+
     return(
         <>
         <div className="mx-auto max-w-7xl px-4 sm:px-6 lg:px-8 py-8">
@@ -21,20 +42,25 @@ export default function ReferPoint(){
                 Share your link
                 </h2>
                 <p className="text-sm text-gray-600">
-                Invite friends and earn <span className="font-medium text-purple-700">25 pts</span> when they join
+                Invite friends and earn <span className="font-medium text-purple-700">{referrals.pointsPerReferral} pts</span> when they verify their email
+                </p>
+                {referralLink && (
+                <p className="mt-1 break-all text-xs text-purple-700">
+                {referralLink}
                 </p>
+                )}
             </div>
             </div>
 
             {/* Stats */}
             <div className="flex justify-between rounded-md bg-white px-6 py-4 text-center">
             <div>
-                <p className="text-lg font-semibold text-gray-900">0</p>
+                <p className="text-lg font-semibold text-gray-900">{referrals.referralCount}</p>
                 <p className="text-xs text-gray-500">Referrals</p>
             </div>
 
             <div>
-                <p className="text-lg font-semibold text-gray-700">0</p>
+                <p className="text-lg font-semibold text-gray-700">{referrals.pointsEarned}</p>
                 <p className="text-xs text-gray-500">Points Earned</p>
             </div>
             </div>
@@ -44,4 +70,4 @@ export default function ReferPoint(){
 
         </>
     )
-}
\ No newline at end of file
+}
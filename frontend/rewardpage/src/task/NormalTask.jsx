@@ -2,16 +2,20 @@
 // CHANGE: Fixed debugging issues and added safety checks
 
 import { completeTaskDaily, getDailyTasks } from "../api/apitask";
+import { apiError } from "../api/api";
 import { useState, useEffect } from "react";
 import { CheckCircle2, Clock, AlertCircle } from "lucide-react";
 
-const TASK_COUNT = 5;
 const COOLDOWN_MINUTES = 5;
+// Fallback for tasks that carry no point value (backend default)
 const POINTS_PER_TASK = 20;
 
+// Task count and point values come from the backend task templates
+const taskPoints = (task) => (task && task.points) || POINTS_PER_TASK;
+
 function NormalTasks({ onComplete, onPointsUpdate }) {
   
-  // CHANGE: State for 5-task daily checklist system
+  // CHANGE: State for daily checklist system (task count varies per day)
   const [tasks, setTasks] = useState([]);
   const [completedCount, setCompletedCount] = useState(0);
   const [loading, setLoading] = useState(true);
@@ -27,7 +31,7 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
         setLoading(true);
         // CHANGE: Fetch today's task state from backend
         // Backend endpoint: GET /api/tasks/daily
-        // Returns: { tasks: [{ id, number, completed, completedAt }], completedCount, lastCompletedAt }
+        // Returns: { tasks: [{ id, number, title, points, completed, completedAt }], completedCount, lastCompletedAt }
         const response = await getDailyTasks();
         
         // CHANGE: Added safety check for tasks array
@@ -106,8 +110,8 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
     console.log('Attempting to complete task:', { taskId: taskIdStr, type: typeof taskId });
 
     // CHANGE: Prevent completion if daily limit reached
-    if (completedCount >= TASK_COUNT) {
-      setError('✅ All 5 daily tasks completed! Return tomorrow for more.');
+    if (completedCount >= tasks.length) {
+      setError('✅ All daily tasks completed! Return tomorrow for more.');
       return;
     }
 
@@ -125,7 +129,7 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
       // Backend endpoint: POST /api/tasks/complete
       // Body: { taskId }
       // Backend validation:
-      // - Checks if user already completed 5 tasks today
+      // - Checks if user already completed all of today's tasks
       // - Checks if user is within cooldown period (5 minutes)
       // - Updates lastCompletedAt timestamp
       // - Returns updated task state
@@ -133,15 +137,14 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
       console.log('Task completion response:', response);
 
       // CHANGE: Added safety check for response
-      if (response && !response.error && response.success) {
+      if (response && response.success) {
         // CHANGE: Update local state after successful backend completion
-        setTasks((prev) =>
-          prev.map((task) =>
-            (task.id === taskIdStr)
-              ? { ...task, completed: true, completedAt: new Date() }
-              : task
-          )
+        const updatedTasks = tasks.map((task) =>
+          (task.id === taskIdStr)
+            ? { ...task, completed: true, completedAt: new Date() }
+            : task
         );
+        setTasks(updatedTasks);
 
         const newCompletedCount = completedCount + 1;
         setCompletedCount(newCompletedCount);
@@ -151,7 +154,9 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
         setCooldownTime(COOLDOWN_MINUTES * 60);
 
         // CHANGE: Calculate and update total points
-        const totalPoints = newCompletedCount * POINTS_PER_TASK;
+        const totalPoints = updatedTasks
+          .filter((task) => task.completed)
+          .reduce((sum, task) => sum + taskPoints(task), 0);
         if (typeof onPointsUpdate === 'function') {
           onPointsUpdate(totalPoints);
         }
@@ -161,31 +166,34 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
         }
 
         // CHANGE: Show completion message
-        if (newCompletedCount === TASK_COUNT) {
-          setError('🎉 All 5 tasks completed today! Great job!');
+        if (newCompletedCount === tasks.length) {
+          setError('🎉 All tasks completed today! Great job!');
         }
-      } else if (response?.error) {
-        // CHANGE: Handle specific error responses from backend
-        const errorMsg = response.error || 'Failed to complete task';
-        setError(errorMsg.includes('cooldown') ? `⏳ ${errorMsg}` : errorMsg);
       } else {
         // CHANGE: Handle unsuccessful response
         setError(response?.message || 'Failed to complete task');
       }
     } catch (err) {
       console.error('Failed to complete task:', err);
-      // CHANGE: Better error handling with fallback messages
-      let errorMessage = 'Failed to complete task. Please try again.';
-      
-      if (err.response?.status === 429) {
-        // CHANGE: Handle rate limiting (cooldown)
-        errorMessage = `⏳ Cooldown active. Please wait before trying again.`;
-      } else if (err.response?.data?.error) {
-        errorMessage = err.response.data.error;
-      } else if (err.response?.data?.message) {
-        errorMessage = err.response.data.message;
-      } else if (err.message) {
-        errorMessage = err.message;
+      // Backend errors carry a stable code; the message is display text
+      const { code, message, details } = apiError(err);
+      let errorMessage;
+
+      switch (code) {
+        case 'cooldown_active':
+          setIsCooldownActive(true);
+          setCooldownTime(details?.remainingSeconds ?? COOLDOWN_MINUTES * 60);
+          errorMessage = `⏳ ${message}`;
+          break;
+        case 'daily_limit_reached':
+          errorMessage = '🎉 All tasks completed today! Come back tomorrow.';
+          break;
+        case 'task_already_completed':
+        case 'task_not_found':
+          errorMessage = message;
+          break;
+        default:
+          errorMessage = message || 'Failed to complete task. Please try again.';
       }
       
       setError(errorMessage);
@@ -203,9 +211,13 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
   };
 
   // CHANGE: Calculate remaining tasks and points
-  const totalPoints = completedCount * POINTS_PER_TASK;
-  const progressPercent = tasks.length > 0 ? (completedCount / TASK_COUNT) * 100 : 0;
-  const remainingTasks = TASK_COUNT - completedCount;
+  const taskCount = tasks.length;
+  const totalPoints = tasks
+    .filter((task) => task && task.completed)
+    .reduce((sum, task) => sum + taskPoints(task), 0);
+  const maxPoints = tasks.reduce((sum, task) => sum + taskPoints(task), 0);
+  const progressPercent = taskCount > 0 ? (completedCount / taskCount) * 100 : 0;
+  const remainingTasks = taskCount - completedCount;
 
   if (loading) {
     return (
@@ -240,7 +252,7 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
         <div className="space-y-2">
           <div className="flex justify-between items-center">
             <span className="text-sm font-medium text-gray-700">
-              {completedCount} of {TASK_COUNT} tasks completed
+              {completedCount} of {taskCount} tasks completed
             </span>
             <span className="text-xs font-semibold text-gray-500">
               {remainingTasks} remaining • {Math.round(progressPercent)}%
@@ -285,7 +297,7 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
         )}
       </div>
 
-      {/* 5 Task Buttons Grid */}
+      {/* Task Buttons Grid */}
       <div className="grid grid-cols-2 sm:grid-cols-3 lg:grid-cols-5 gap-2">
         {tasks.map((task, index) => {
           if (!task || typeof task !== "object") {
@@ -312,7 +324,7 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
                 <div className="flex flex-col items-center gap-1">
                   <CheckCircle2 className="h-5 w-5 text-purple-600" />
                   <span className="text-[10px] font-semibold text-purple-700">
-                    +{POINTS_PER_TASK} pts
+                    +{taskPoints(task)} pts
                   </span>
                 </div>
               ) : (
@@ -321,10 +333,10 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
                   disabled={
                     loadingTaskId === task.id ||
                     isCooldownActive ||
-                    completedCount >= TASK_COUNT
+                    completedCount >= taskCount
                   }
                   className={`w-full h-full flex flex-col items-center justify-center gap-1 rounded-md text-xs font-semibold transition-all ${
-                    completedCount >= TASK_COUNT
+                    completedCount >= taskCount
                       ? "bg-gray-100 text-gray-400 cursor-not-allowed"
                       : isCooldownActive
                       ? "bg-purple-100 text-purple-600 cursor-wait"
@@ -335,11 +347,11 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
                   title={
                     isCooldownActive
                       ? `Wait ${cooldownTime}s before next task`
-                      : "Complete task"
+                      : task.description || "Complete task"
                   }
                 >
-                  <span>Task {task.number || index + 1}</span>
-                  <span className="text-[10px]">+{POINTS_PER_TASK}</span>
+                  <span>{task.title || `Task ${task.number || index + 1}`}</span>
+                  <span className="text-[10px]">+{taskPoints(task)}</span>
                 </button>
               )}
             </div>
@@ -354,9 +366,9 @@ function NormalTasks({ onComplete, onPointsUpdate }) {
           📋 Daily Task Checklist Rules
         </p>
         <ul className="text-xs text-purple-800 space-y-1 ml-4">
-          <li>✓ Complete exactly <strong>5 tasks per day</strong></li>
+          <li>✓ Complete all <strong>{taskCount} tasks today</strong></li>
           <li>✓ <strong>5-minute cooldown</strong> enforced between task completions</li>
-          <li>✓ Earn <strong>{POINTS_PER_TASK} points per task</strong> ({TASK_COUNT * POINTS_PER_TASK} total per day)</li>
+          <li>✓ Earn <strong>points per task</strong> ({maxPoints} total today)</li>
           <li>✓ Completed tasks become <strong className="text-green-700">disabled and highlighted</strong></li>
           <li>✓ Tasks <strong>unlock sequentially every 5 minutes</strong> after each completion</li>
           <li>✓ Tasks <strong>automatically reset at midnight</strong></li>
@@ -433,4 +445,4 @@ export default NormalTasks;
             </div>
           );
         })}
-      </div> */}
\ No newline at end of file
+      </div> */}
\ No newline at end of file